@@ -0,0 +1,109 @@
+package odm
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+// retryUntil 执行 fn 直到没有未处理的数据，或者已达到 kind 对应 RetryPolicy 的 MaxAttempts、在下一次重试前已经超过 deadline、ctx 被取消。
+// fn 的参数为 db，重试时 db 的 ctx 带有重试次数；fn 返回 true 表示全部处理完毕。deadline 为零值时不限制时间。
+// db 没有配置 RetryPolicy 时使用 DefaultRetryPolicy
+func retryUntil(db *ODMDB, kind string, deadline time.Time, fn func(db *ODMDB) (bool, error)) error {
+	ctx := db.Context()
+	policy := db.retryPolicy()
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+	policy = policy.ForOperation(kind)
+	for attempt := 0; ; attempt++ {
+		target := db
+		if attempt > 0 {
+			target = db.WithContext(withRetry(ctx, attempt))
+		}
+		done, err := fn(target)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if policy == nil || attempt+1 >= policy.MaxAttempts {
+			return ErrUnprocessed
+		}
+		delay := policy.Delay(attempt)
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return ErrUnprocessed
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// BatchWriteItemWithRetry 批量写入，并按 db 的 RetryPolicy 重试未处理的数据，直到全部完成或超过 deadline。
+// 超过 deadline 或 MaxAttempts 时返回 ErrUnprocessed，剩余的数据填充到 unprocessedItems（可以为 nil）
+func (db *ODMDB) BatchWriteItemWithRetry(options []*BatchWrite, deadline time.Time, unprocessedItems *[]*BatchWrite) error {
+	pending := options
+	err := retryUntil(db, OpBatchWriteItem, deadline, func(db *ODMDB) (bool, error) {
+		unprocessed := []*BatchWrite{}
+		err := db.BatchWriteItem(pending, &unprocessed)
+		pending = unprocessed
+		return len(unprocessed) == 0, err
+	})
+	if unprocessedItems != nil {
+		*unprocessedItems = append(*unprocessedItems, pending...)
+	}
+	return err
+}
+
+// BatchGetItemWithRetry 批量读取，并按 db 的 RetryPolicy 重试未处理的主键，直到全部完成或超过 deadline。
+// 每次重试读到的 item 会追加到 results[i]，options 中的 TableName 不能重复。
+// 超过 deadline 或 MaxAttempts 时返回 ErrUnprocessed，剩余的主键填充到 unprocessedItems（可以为 nil）
+// Example:
+//
+//	books := []Book{}
+//	db.BatchGetItemWithRetry([]*odm.BatchGet{
+//		{TableName: "book", KeyPairs: []odm.KeyPair{{HashKey: "Tom", RangeKey: "Hello"}}},
+//	}, time.Now().Add(time.Second), nil, &books)
+func (db *ODMDB) BatchGetItemWithRetry(options []*BatchGet, deadline time.Time, unprocessedItems *[]*BatchGet, results ...interface{}) error {
+	if len(results) < len(options) {
+		return errors.New("BatchGetItem requires a result for each option")
+	}
+	index := map[string]int{}
+	for i, opt := range options {
+		index[opt.TableName] = i
+		if err := clearSlice(results[i]); err != nil {
+			return err
+		}
+	}
+	pending := options
+	err := retryUntil(db, OpBatchGetItem, deadline, func(db *ODMDB) (bool, error) {
+		unprocessed := []*BatchGet{}
+		pages := make([]interface{}, len(pending))
+		for i, opt := range pending {
+			pages[i] = reflect.New(reflect.TypeOf(results[index[opt.TableName]]).Elem()).Interface()
+		}
+		err := db.BatchGetItem(pending, &unprocessed, pages...)
+		for i, opt := range pending {
+			result := reflect.ValueOf(results[index[opt.TableName]]).Elem()
+			result.Set(reflect.AppendSlice(result, reflect.ValueOf(pages[i]).Elem()))
+		}
+		pending = unprocessed
+		return len(unprocessed) == 0, err
+	})
+	if unprocessedItems != nil {
+		*unprocessedItems = append(*unprocessedItems, pending...)
+	}
+	return err
+}
+
+// clearSlice 将 *[]T 置为空 slice
+func clearSlice(slicePtr interface{}) error {
+	v := reflect.ValueOf(slicePtr)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("BatchGetItem results should be pointers to slice")
+	}
+	v.Elem().Set(reflect.MakeSlice(v.Elem().Type(), 0, 0))
+	return nil
+}
@@ -0,0 +1,112 @@
+package odm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyDB 前 failures 次 BatchWriteItem 只处理第一个写操作
+type flakyDB struct {
+	DialectDB
+	failures int
+	calls    int
+	written  []Map
+}
+
+func (db *flakyDB) BatchWriteItem(options []*BatchWrite, unprocessedItems *[]*BatchWrite) error {
+	db.calls++
+	keys := []Map{}
+	for _, opt := range options {
+		keys = append(keys, opt.DeleteKeys...)
+	}
+	if db.calls <= db.failures && len(keys) > 1 {
+		db.written = append(db.written, keys[0])
+		*unprocessedItems = append(*unprocessedItems, &BatchWrite{TableName: "t", DeleteKeys: keys[1:]})
+		return nil
+	}
+	db.written = append(db.written, keys...)
+	return nil
+}
+
+func (db *flakyDB) WithContext(ctx context.Context) DialectDB {
+	return db
+}
+
+// BatchGetItem 前 failures 次只返回第一个主键对应的 item
+func (db *flakyDB) BatchGetItem(options []*BatchGet, unprocessedItems *[]*BatchGet, results ...interface{}) error {
+	db.calls++
+	for i, opt := range options {
+		keys := opt.Keys
+		if db.calls <= db.failures && len(keys) > 1 {
+			*unprocessedItems = append(*unprocessedItems, &BatchGet{TableName: opt.TableName, Keys: keys[1:]})
+			keys = keys[:1]
+		}
+		*results[i].(*[]Map) = keys
+	}
+	return nil
+}
+
+func TestODMDB_BatchWriteItemWithRetry(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 100, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	writes := []*BatchWrite{{TableName: "t", DeleteKeys: []Map{{"id": 1}, {"id": 2}, {"id": 3}}}}
+
+	dialect := &flakyDB{failures: 2}
+	db := &ODMDB{DialectDB: dialect}
+	db.setRetryPolicy(policy)
+	err := db.BatchWriteItemWithRetry(writes, time.Now().Add(time.Second), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, dialect.calls)
+	assert.Equal(t, []Map{{"id": 1}, {"id": 2}, {"id": 3}}, dialect.written)
+
+	dialect = &flakyDB{failures: 100}
+	db = &ODMDB{DialectDB: dialect}
+	unprocessed := []*BatchWrite{}
+	err = db.BatchWriteItemWithRetry(writes, time.Now(), &unprocessed)
+	assert.Equal(t, ErrUnprocessed, err)
+	assert.Equal(t, []*BatchWrite{{TableName: "t", DeleteKeys: []Map{{"id": 2}, {"id": 3}}}}, unprocessed)
+
+	// 达到 RetryPolicy 的 MaxAttempts 后不再重试，Operations 可以单独配置批量操作
+	dialect = &flakyDB{failures: 100}
+	db = &ODMDB{DialectDB: dialect}
+	db.setRetryPolicy(&RetryPolicy{MaxAttempts: 100, Operations: map[string]*RetryPolicy{
+		OpBatchWriteItem: {MaxAttempts: 2, BaseDelay: time.Millisecond},
+	}})
+	err = db.BatchWriteItemWithRetry(writes, time.Time{}, nil)
+	assert.Equal(t, ErrUnprocessed, err)
+	assert.Equal(t, 2, dialect.calls)
+	dialect = &flakyDB{failures: 100}
+	db = &ODMDB{DialectDB: dialect}
+	db.setRetryPolicy(nil)
+	err = db.BatchWriteItemWithRetry(writes, time.Time{}, nil)
+	assert.Equal(t, ErrUnprocessed, err)
+	assert.Equal(t, 1, dialect.calls)
+
+	// ctx 取消后不再重试
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dialect = &flakyDB{failures: 100}
+	db = (&ODMDB{DialectDB: dialect}).WithContext(ctx)
+	err = db.BatchWriteItemWithRetry(writes, time.Time{}, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, dialect.calls)
+}
+
+func TestODMDB_BatchGetItemWithRetry(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 100, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	gets := []*BatchGet{
+		{TableName: "a", Keys: []Map{{"id": 1}, {"id": 2}}},
+		{TableName: "b", Keys: []Map{{"id": 3}, {"id": 4}, {"id": 5}}},
+	}
+	dialect := &flakyDB{failures: 2}
+	db := &ODMDB{DialectDB: dialect}
+	db.setRetryPolicy(policy)
+	a := []Map{{"id": 0}}
+	b := []Map{}
+	err := db.BatchGetItemWithRetry(gets, time.Now().Add(time.Second), nil, &a, &b)
+	assert.NoError(t, err)
+	assert.Equal(t, []Map{{"id": 1}, {"id": 2}}, a)
+	assert.Equal(t, []Map{{"id": 3}, {"id": 4}, {"id": 5}}, b)
+}
@@ -0,0 +1,35 @@
+package odm
+
+import "context"
+
+// ReturnConsumedCapacity 的取值，为空时由 DB 决定：设置了 ConsumedCapacityHandler 时为 INDEXES，否则为 NONE
+const (
+	// ConsumedCapacityNone 不返回消耗的容量
+	ConsumedCapacityNone = "NONE"
+	// ConsumedCapacityTotal 只返回总的消耗
+	ConsumedCapacityTotal = "TOTAL"
+	// ConsumedCapacityIndexes 返回总的消耗，以及表和每个索引各自的消耗
+	ConsumedCapacityIndexes = "INDEXES"
+)
+
+// Capacity 消耗的容量单位
+type Capacity struct {
+	CapacityUnits      float64
+	ReadCapacityUnits  float64
+	WriteCapacityUnits float64
+}
+
+// ConsumedCapacity 一次请求在一个表上消耗的容量
+type ConsumedCapacity struct {
+	TableName string
+	// Capacity 总的消耗，包含表和索引
+	Capacity
+	// 以下字段只在 ReturnConsumedCapacity 为 INDEXES 时返回
+	Table                  *Capacity
+	GlobalSecondaryIndexes map[string]*Capacity
+	LocalSecondaryIndexes  map[string]*Capacity
+}
+
+// ConsumedCapacityHandler 接收每次请求消耗的容量，operation 为 DynamoDB 的操作名，如 Query、BatchWriteItem。
+// 批量操作和事务涉及多个表时 capacities 中每个表各有一项，重试的每次请求都会调用一次
+type ConsumedCapacityHandler func(ctx context.Context, operation string, capacities []*ConsumedCapacity)
@@ -0,0 +1,228 @@
+// Package codec 根据 odm.GetModelMeta 得到的字段定义，在 Model 与 DynamoDB 数据模型之间转换。
+//
+// 属性名使用 FieldDefine.GetDBFieldName，OmitEmpty 的字段为空时不写入（主键除外），
+// 保证写入、读取、主键和建表使用的属性名一致。结构体以外的类型（如 odm.Map）交给 dynamodbattribute 处理。
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Schema 字段名所在的 schema，与 dynamo、memory 方言一致
+const Schema = "dynamodb"
+
+// Item 以 DynamoDB 数据模型存储的一条记录
+type Item = map[string]*dynamodb.AttributeValue
+
+// metas 缓存结构体类型对应的字段定义
+var metas sync.Map
+
+func structMeta(t reflect.Type) *odm.TableMeta {
+	if meta, ok := metas.Load(t); ok {
+		return meta.(*odm.TableMeta)
+	}
+	meta := odm.GetModelMeta(reflect.New(t).Interface())
+	metas.Store(t, meta)
+	return meta
+}
+
+// structValue 返回 model 指向的结构体，model 不是结构体或自行实现了编码时返回 false
+func structValue(model interface{}) (reflect.Value, bool) {
+	if _, ok := model.(dynamodbattribute.Marshaler); ok {
+		return reflect.Value{}, false
+	}
+	if _, ok := model.(dynamodbattribute.Unmarshaler); ok {
+		return reflect.Value{}, false
+	}
+	v := reflect.ValueOf(model)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
+
+// Marshal 将 model 编码为 Item
+func Marshal(model interface{}) (Item, error) {
+	v, ok := structValue(model)
+	if !ok {
+		return dynamodbattribute.MarshalMap(model)
+	}
+	meta := structMeta(v.Type())
+	item := Item{}
+	for _, f := range meta.Fields {
+		fv := odm.FieldByName(v, f.ModelFieldName)
+		if !fv.IsValid() {
+			// 所在的嵌入结构体指针为 nil
+			continue
+		}
+		if f.OmitEmpty && !f.PK && !f.SK && odm.IsEmptyValue(fv) {
+			continue
+		}
+		av, err := marshalField(f, fv)
+		if err != nil {
+			return nil, err
+		}
+		item[f.GetDBFieldName(Schema)] = av
+	}
+	return item, nil
+}
+
+// Unmarshal 将 item 解码到 model，item 中不存在的字段保持原样
+func Unmarshal(item Item, model interface{}) error {
+	if reflect.ValueOf(model).Kind() != reflect.Ptr {
+		return dynamodbattribute.UnmarshalMap(item, model)
+	}
+	v, ok := structValue(model)
+	if !ok {
+		return dynamodbattribute.UnmarshalMap(item, model)
+	}
+	meta := structMeta(v.Type())
+	for _, f := range meta.Fields {
+		av := item[f.GetDBFieldName(Schema)]
+		if av == nil {
+			continue
+		}
+		fv, ok := settableField(v, f.ModelFieldName)
+		if !ok {
+			continue
+		}
+		if err := unmarshalField(f, av, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optionKey 字段类型与 dynamodbav 选项
+type optionKey struct {
+	t       reflect.Type
+	options string
+}
+
+// optionTypes 缓存带有 dynamodbav 选项的包装结构体类型
+var optionTypes sync.Map
+
+// optionType 返回只有一个字段 V 的结构体类型，V 的 dynamodbav 注解带有 options，
+// 由 dynamodbattribute 按 stringset、string 等选项编码单个字段
+func optionType(t reflect.Type, options []string) reflect.Type {
+	key := optionKey{t: t, options: strings.Join(options, ",")}
+	if wrapper, ok := optionTypes.Load(key); ok {
+		return wrapper.(reflect.Type)
+	}
+	wrapper := reflect.StructOf([]reflect.StructField{{
+		Name: "V",
+		Type: t,
+		Tag:  reflect.StructTag(`dynamodbav:"v,` + key.options + `"`),
+	}})
+	optionTypes.Store(key, wrapper)
+	return wrapper
+}
+
+// marshalField 按字段的 EncodeOptions 编码字段值
+func marshalField(f *odm.FieldDefine, fv reflect.Value) (*dynamodb.AttributeValue, error) {
+	if len(f.EncodeOptions) == 0 {
+		return dynamodbattribute.Marshal(fv.Interface())
+	}
+	wrapper := reflect.New(optionType(fv.Type(), f.EncodeOptions)).Elem()
+	wrapper.Field(0).Set(fv)
+	av, err := dynamodbattribute.Marshal(wrapper.Interface())
+	if err != nil {
+		return nil, err
+	}
+	if v := av.M["v"]; v != nil {
+		return v, nil
+	}
+	return dynamodbattribute.Marshal(nil)
+}
+
+// unmarshalField 按字段的 EncodeOptions 将 av 解码到 fv
+func unmarshalField(f *odm.FieldDefine, av *dynamodb.AttributeValue, fv reflect.Value) error {
+	if len(f.EncodeOptions) == 0 {
+		return dynamodbattribute.Unmarshal(av, fv.Addr().Interface())
+	}
+	wrapper := reflect.New(optionType(fv.Type(), f.EncodeOptions))
+	if err := dynamodbattribute.UnmarshalMap(Item{"v": av}, wrapper.Interface()); err != nil {
+		return err
+	}
+	fv.Set(wrapper.Elem().Field(0))
+	return nil
+}
+
+// settableField 返回结构体 v 中名为 name 的字段，为 nil 的嵌入结构体指针会被创建，无法创建（未导出）时返回 false
+func settableField(v reflect.Value, name string) (reflect.Value, bool) {
+	sf, _ := v.Type().FieldByName(name)
+	for i, x := range sf.Index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// UnmarshalList 将 items 解码到 results，results 为指向 slice 的指针，原有的元素会被替换
+func UnmarshalList(items []Item, results interface{}) error {
+	rv := reflect.ValueOf(results)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("results should be a pointer to slice")
+	}
+	slice := reflect.MakeSlice(rv.Elem().Type(), len(items), len(items))
+	for i, item := range items {
+		elem := slice.Index(i)
+		target := elem.Addr()
+		if elem.Kind() == reflect.Ptr {
+			elem.Set(reflect.New(elem.Type().Elem()))
+			target = elem
+		}
+		if err := Unmarshal(item, target.Interface()); err != nil {
+			return err
+		}
+	}
+	rv.Elem().Set(slice)
+	return nil
+}
+
+// MarshalKey 编码 ExclusiveStartKey 等主键，json.Number 编码为数字
+func MarshalKey(key odm.Map) (Item, error) {
+	converted := make(map[string]interface{}, len(key))
+	for k, v := range key {
+		if n, ok := v.(json.Number); ok {
+			v = dynamodbattribute.Number(n)
+		}
+		converted[k] = v
+	}
+	return dynamodbattribute.MarshalMap(converted)
+}
+
+// UnmarshalKey 将 LastEvaluatedKey 等主键解码到 key，数字解码为 json.Number，不会丢失精度
+func UnmarshalKey(item Item, key odm.Map) error {
+	for k, av := range item {
+		if av.N != nil {
+			key[k] = json.Number(*av.N)
+			continue
+		}
+		var v interface{}
+		if err := dynamodbattribute.Unmarshal(av, &v); err != nil {
+			return err
+		}
+		key[k] = v
+	}
+	return nil
+}
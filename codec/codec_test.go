@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Timestamps struct {
+	CreatedAt int64 `json:"created_at"`
+}
+
+type Book struct {
+	Timestamps
+	Author   string            `odm:"PK" json:"author,omitempty"`
+	Title    string            `odm:"SK" dynamodbav:"subject"`
+	Age      int64             `json:",omitempty"`
+	Nick     *string           `dynamodbav:"nick,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+func TestMarshal(t *testing.T) {
+	item, err := Marshal(&Book{
+		Timestamps: Timestamps{CreatedAt: 1},
+		Author:     "Tom",
+		Title:      "Hello",
+		Tags:       map[string]string{"a": "b"},
+		Ignored:    "x",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, Item{
+		"created_at": {N: aws.String("1")},
+		"author":     {S: aws.String("Tom")},
+		"subject":    {S: aws.String("Hello")},
+		"tags":       {M: map[string]*dynamodb.AttributeValue{"a": {S: aws.String("b")}}},
+	}, item)
+
+	// 主键即使为空也会写入
+	item, err = Marshal(Book{})
+	assert.NoError(t, err)
+	assert.Contains(t, item, "author")
+	assert.NotContains(t, item, "Age")
+
+	item, err = Marshal(odm.Map{"a": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, Item{"a": {N: aws.String("1")}}, item)
+}
+
+func TestUnmarshal(t *testing.T) {
+	nick := "tom"
+	book := &Book{Timestamps: Timestamps{CreatedAt: 1}, Author: "Tom", Title: "Hello", Age: 3, Nick: &nick}
+	item, err := Marshal(book)
+	assert.NoError(t, err)
+
+	result := &Book{Ignored: "keep"}
+	assert.NoError(t, Unmarshal(item, result))
+	assert.Equal(t, &Book{Timestamps: Timestamps{CreatedAt: 1}, Author: "Tom", Title: "Hello", Age: 3, Nick: &nick, Ignored: "keep"}, result)
+
+	m := odm.Map{}
+	assert.NoError(t, Unmarshal(item, &m))
+	assert.Equal(t, "Hello", m["subject"])
+
+	assert.Error(t, Unmarshal(item, Book{}))
+}
+
+func TestUnmarshalList(t *testing.T) {
+	items := []Item{
+		{"author": {S: aws.String("Tom")}, "subject": {S: aws.String("A")}},
+		{"author": {S: aws.String("Jack")}, "subject": {S: aws.String("B")}},
+	}
+	books := []Book{{Author: "old"}}
+	assert.NoError(t, UnmarshalList(items, &books))
+	assert.Equal(t, []Book{{Author: "Tom", Title: "A"}, {Author: "Jack", Title: "B"}}, books)
+
+	ptrs := []*Book{}
+	assert.NoError(t, UnmarshalList(items, &ptrs))
+	assert.Equal(t, []*Book{{Author: "Tom", Title: "A"}, {Author: "Jack", Title: "B"}}, ptrs)
+
+	maps := []odm.Map{}
+	assert.NoError(t, UnmarshalList(items, &maps))
+	assert.Equal(t, []odm.Map{{"author": "Tom", "subject": "A"}, {"author": "Jack", "subject": "B"}}, maps)
+
+	assert.Error(t, UnmarshalList(items, books))
+}
+
+type Owner struct {
+	OwnerName string `json:"owner_name"`
+}
+
+type Pet struct {
+	*Timestamps
+	*Owner
+	Id string `odm:"PK"`
+}
+
+func TestMarshal_PointerEmbed(t *testing.T) {
+	// 与 dynamodbattribute 一致：展开嵌入的结构体指针，为 nil 时不写入
+	for _, pet := range []*Pet{
+		{Id: "1", Owner: &Owner{OwnerName: "Tom"}},
+		{Id: "2", Timestamps: &Timestamps{CreatedAt: 1}, Owner: &Owner{}},
+	} {
+		item, err := Marshal(pet)
+		assert.NoError(t, err)
+		expected, err := dynamodbattribute.MarshalMap(pet)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, item)
+	}
+
+	pet := &Pet{}
+	assert.NoError(t, Unmarshal(Item{
+		"Id":         {S: aws.String("1")},
+		"owner_name": {S: aws.String("Tom")},
+	}, pet))
+	assert.Equal(t, &Pet{Id: "1", Owner: &Owner{OwnerName: "Tom"}}, pet)
+}
+
+type Tagged struct {
+	Id     string   `odm:"PK"`
+	Tags   []string `dynamodbav:"tags,stringset"`
+	Scores []int    `dynamodbav:"scores,numberset,omitempty"`
+	Level  int      `dynamodbav:"level,string"`
+}
+
+func TestMarshal_EncodeOptions(t *testing.T) {
+	tagged := &Tagged{Id: "1", Tags: []string{"a", "b"}, Level: 3}
+	item, err := Marshal(tagged)
+	assert.NoError(t, err)
+	expected, err := dynamodbattribute.MarshalMap(tagged)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, item)
+	assert.Equal(t, []*string{aws.String("a"), aws.String("b")}, item["tags"].SS)
+	assert.Equal(t, "3", aws.StringValue(item["level"].S))
+
+	// 已有的 SS、NS 和字符串形式的数字可以读回
+	result := &Tagged{}
+	item["scores"] = &dynamodb.AttributeValue{NS: []*string{aws.String("1"), aws.String("2")}}
+	assert.NoError(t, Unmarshal(item, result))
+	assert.Equal(t, &Tagged{Id: "1", Tags: []string{"a", "b"}, Scores: []int{1, 2}, Level: 3}, result)
+}
+
+func TestMarshalKey(t *testing.T) {
+	item := Item{
+		"id":  {N: aws.String("9007199254740993")},
+		"sk":  {S: aws.String("a")},
+		"bin": {B: []byte{1}},
+	}
+	key := odm.Map{}
+	assert.NoError(t, UnmarshalKey(item, key))
+	assert.Equal(t, odm.Map{"id": json.Number("9007199254740993"), "sk": "a", "bin": []byte{1}}, key)
+	marshaled, err := MarshalKey(key)
+	assert.NoError(t, err)
+	assert.Equal(t, item, marshaled)
+}
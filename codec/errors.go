@@ -0,0 +1,27 @@
+package codec
+
+import (
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// TransactionCanceledError 将 DynamoDB 的取消原因转换为 odm.TransactionCanceledError，dynamo、memory 方言共用。
+// 旧数据以 Unmarshal 填充到 Model
+func TransactionCanceledError(e *dynamodb.TransactionCanceledException) error {
+	reasons := make([]*odm.CancellationReason, len(e.CancellationReasons))
+	for i, reason := range e.CancellationReasons {
+		var old odm.Map
+		raw := reason.Item
+		if raw != nil {
+			if err := dynamodbattribute.UnmarshalMap(raw, &old); err != nil {
+				return err
+			}
+		}
+		reasons[i] = odm.NewCancellationReason(aws.StringValue(reason.Code), aws.StringValue(reason.Message), old, func(result odm.Model) error {
+			return Unmarshal(raw, result)
+		})
+	}
+	return odm.NewTransactionCanceledError(reasons, e)
+}
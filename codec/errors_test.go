@@ -0,0 +1,37 @@
+package codec
+
+import (
+	"testing"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Account struct {
+	Id      string `odm:"PK" dynamodbav:"id"`
+	Balance int64  `dynamodbav:"balance"`
+}
+
+func TestTransactionCanceledError(t *testing.T) {
+	err := TransactionCanceledError(&dynamodb.TransactionCanceledException{
+		CancellationReasons: []*dynamodb.CancellationReason{
+			{Code: aws.String("None")},
+			{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: aws.String("The conditional request failed"),
+				Item:    map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}, "balance": {N: aws.String("100")}},
+			},
+		},
+	})
+	canceled, ok := err.(*odm.TransactionCanceledError)
+	assert.True(t, ok)
+	assert.Len(t, canceled.Reasons, 2)
+	assert.Nil(t, canceled.Reasons[0].Item)
+	assert.Equal(t, odm.Map{"id": "1", "balance": float64(100)}, canceled.Reasons[1].Item)
+	account := &Account{}
+	assert.NoError(t, canceled.Reasons[1].Unmarshal(account))
+	assert.Equal(t, &Account{Id: "1", Balance: 100}, account)
+}
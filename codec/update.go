@@ -0,0 +1,61 @@
+package codec
+
+import (
+	"reflect"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Value 已经按字段定义编码的值，作为 ValueParams 时原样写入
+type Value struct {
+	*dynamodb.AttributeValue
+}
+
+// MarshalDynamoDBAttributeValue implements dynamodbattribute.Marshaler
+func (v Value) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	*av = *v.AttributeValue
+	return nil
+}
+
+// ModelFields 与 odm.ModelFields 相同，但非 nil 的值按字段定义（如 stringset、string 选项）编码为 Value
+func ModelFields(model odm.Model, fields ...string) (odm.Map, error) {
+	values, err := odm.ModelFields(model, Schema, fields...)
+	if err != nil {
+		return nil, err
+	}
+	meta := odm.GetModelMeta(model)
+	v := reflect.Indirect(reflect.ValueOf(model))
+	for _, f := range meta.Fields {
+		name := f.GetDBFieldName(Schema)
+		if values[name] == nil {
+			continue
+		}
+		av, err := marshalField(f, odm.FieldByName(v, f.ModelFieldName))
+		if err != nil {
+			return nil, err
+		}
+		values[name] = Value{av}
+	}
+	return values, nil
+}
+
+// UpdateModel 根据 model 的主键更新 item，只更新 model 中的部分字段，供方言实现 Table.UpdateModel。
+// fields 为空时更新所有非空的字段，否则只更新 fields 列出的字段，见 odm.ModelFields
+// Example:
+//
+//	// 只更新 Age
+//	table.UpdateModel(&Book{Author: "Tom", Title: "Hello", Age: 3}, nil, nil, nil)
+//	// 将 Age 更新为 0
+//	table.UpdateModel(&Book{Author: "Tom", Title: "Hello"}, []string{"Age"}, nil, nil)
+func UpdateModel(table odm.Table, model odm.Model, fields []string, opt *odm.WriteOption, result odm.Model) error {
+	hashKey, rangeKey, err := odm.ModelKey(model)
+	if err != nil {
+		return err
+	}
+	values, err := ModelFields(model, fields...)
+	if err != nil {
+		return err
+	}
+	return odm.UpdateFields(table, hashKey, rangeKey, values, opt, result)
+}
@@ -0,0 +1,25 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelFields(t *testing.T) {
+	values, err := ModelFields(&Tagged{Id: "1", Tags: []string{"a", "b"}, Level: 3}, "Tags", "Level", "Scores")
+	assert.NoError(t, err)
+	assert.Len(t, values, 3)
+	assert.Nil(t, values["scores"])
+
+	// 作为 ValueParams 编码时保留 stringset、string 选项
+	av, err := dynamodbattribute.MarshalMap(map[string]interface{}{":tags": values["tags"], ":level": values["level"]})
+	assert.NoError(t, err)
+	assert.Equal(t, []*string{aws.String("a"), aws.String("b")}, av[":tags"].SS)
+	assert.Equal(t, "3", aws.StringValue(av[":level"].S))
+
+	_, err = ModelFields(&Tagged{Id: "1"}, "Id")
+	assert.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package odm
+
+// CountResult Table.Count 的计数结果
+type CountResult struct {
+	// Count 满足 Filter 的 item 数量
+	Count int64
+	// ScannedCount 应用 Filter 之前读取的 item 数量，与 Count 的差值反映了 Filter 的效率
+	ScannedCount int64
+}
+
+// CountItems 跟随 LastEvaluatedKey 翻页统计满足 query 的 item 数量，供方言实现 Table.Count。
+// 方言的 Query、Scan 在 results 为 *CountResult 时以 Select COUNT 执行，只返回当前页的计数。
+// query.KeyFilter 为空时执行 Scan，query.Limit 为每一页读取的数量，不限制总数
+// Example:
+//
+//	count, err := table.Count(&odm.QueryOption{KeyFilter: "Author = :a", Filter: "Age > :age", ...})
+//	fmt.Println(count.Count, count.ScannedCount)
+func CountItems(table Table, query *QueryOption) (*CountResult, error) {
+	if query == nil {
+		query = &QueryOption{}
+	}
+	total := &CountResult{}
+	offsetKey := Map{}
+	for {
+		page := CountResult{}
+		if err := table.Query(query, offsetKey, &page); err != nil {
+			return nil, err
+		}
+		total.Count += page.Count
+		total.ScannedCount += page.ScannedCount
+		if len(offsetKey) == 0 {
+			return total, nil
+		}
+	}
+}
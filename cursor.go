@@ -0,0 +1,222 @@
+package odm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidCursor 分页令牌无效：格式错误、签名不匹配或与查询条件不符
+	ErrInvalidCursor = errors.New("Invalid cursor")
+	// ErrCursorExpired 分页令牌已过期
+	ErrCursorExpired = errors.New("Cursor expired")
+)
+
+// Cursor 将 offsetKey 编码为不透明的分页令牌，用于交给客户端继续翻页。
+// 令牌经过加密，不暴露表的主键名，并用 HMAC-SHA256 签名防止伪造；
+// 令牌与查询条件（索引、表达式和参数，不含 Limit）绑定，不能用于其他查询。
+// Example:
+//
+//	cursor := odm.NewCursor([]byte("secret"), time.Hour)
+//	next, err := cursor.Query(table, query, token, &books)
+type Cursor struct {
+	encKey []byte
+	macKey []byte
+	// TTL 令牌的有效期，0 表示不过期
+	TTL time.Duration
+}
+
+// NewCursor 根据密钥创建 Cursor，ttl 为 0 时令牌不过期
+func NewCursor(secret []byte, ttl time.Duration) *Cursor {
+	return &Cursor{
+		encKey: deriveKey(secret, "odm cursor encryption"),
+		macKey: deriveKey(secret, "odm cursor signing"),
+		TTL:    ttl,
+	}
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// cursorValue 带类型的主键值，保证 []byte 和数字解码后类型不变，数字以字符串保存
+type cursorValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+	B []byte  `json:"B,omitempty"`
+}
+
+type cursorPayload struct {
+	Key       map[string]cursorValue `json:"k"`
+	IndexName string                 `json:"i,omitempty"`
+	Query     string                 `json:"q"`
+	ExpiresAt int64                  `json:"e,omitempty"`
+}
+
+// fingerprint 查询条件的摘要，不包含 Limit 和 Consistent
+func fingerprint(query *QueryOption, extra ...interface{}) (string, error) {
+	if query == nil {
+		query = &QueryOption{}
+	}
+	data, err := json.Marshal([]interface{}{
+		query.IndexName, query.KeyFilter, query.Filter, query.Select,
+		query.NameParams, query.ValueParams, query.Desc, extra,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// Encode 将 offsetKey 编码为令牌，offsetKey 为空（已读取完毕）时返回空字符串
+func (c *Cursor) Encode(offsetKey Map, query *QueryOption) (string, error) {
+	return c.encode(offsetKey, query)
+}
+
+// Decode 验证并解码令牌，token 为空时返回空的 offsetKey，即从头开始。数字解码为 json.Number，不会丢失精度
+func (c *Cursor) Decode(token string, query *QueryOption) (Map, error) {
+	return c.decode(token, query)
+}
+
+func (c *Cursor) encode(offsetKey Map, query *QueryOption, extra ...interface{}) (string, error) {
+	if len(offsetKey) == 0 {
+		return "", nil
+	}
+	payload := cursorPayload{
+		Key: map[string]cursorValue{},
+	}
+	if query != nil {
+		payload.IndexName = query.IndexName
+	}
+	var err error
+	if payload.Query, err = fingerprint(query, extra...); err != nil {
+		return "", err
+	}
+	if c.TTL != 0 {
+		payload.ExpiresAt = time.Now().Add(c.TTL).Unix()
+	}
+	for k, v := range offsetKey {
+		switch v := v.(type) {
+		case string:
+			payload.Key[k] = cursorValue{S: &v}
+		case []byte:
+			payload.Key[k] = cursorValue{B: v}
+		case json.Number:
+			n := v.String()
+			payload.Key[k] = cursorValue{N: &n}
+		case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			n := fmt.Sprint(v)
+			payload.Key[k] = cursorValue{N: &n}
+		default:
+			return "", fmt.Errorf("Unsupported key type %T of %s", v, k)
+		}
+	}
+	plain, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	// encrypt-then-MAC: iv | ciphertext | mac
+	block, err := aes.NewCipher(c.encKey)
+	if err != nil {
+		return "", err
+	}
+	data := make([]byte, aes.BlockSize+len(plain))
+	if _, err := rand.Read(data[:aes.BlockSize]); err != nil {
+		return "", err
+	}
+	cipher.NewCTR(block, data[:aes.BlockSize]).XORKeyStream(data[aes.BlockSize:], plain)
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write(data)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(data)), nil
+}
+
+func (c *Cursor) decode(token string, query *QueryOption, extra ...interface{}) (Map, error) {
+	if token == "" {
+		return Map{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) < aes.BlockSize+sha256.Size {
+		return nil, ErrInvalidCursor
+	}
+	data, sum := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write(data)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return nil, ErrInvalidCursor
+	}
+	block, err := aes.NewCipher(c.encKey)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCTR(block, data[:aes.BlockSize]).XORKeyStream(plain, data[aes.BlockSize:])
+	payload := cursorPayload{}
+	if err := json.Unmarshal(plain, &payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if payload.ExpiresAt > 0 && time.Now().Unix() > payload.ExpiresAt {
+		return nil, ErrCursorExpired
+	}
+	expect, err := fingerprint(query, extra...)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Query != expect || (query != nil && payload.IndexName != query.IndexName) {
+		return nil, ErrInvalidCursor
+	}
+	offsetKey := Map{}
+	for k, v := range payload.Key {
+		switch {
+		case v.S != nil:
+			offsetKey[k] = *v.S
+		case v.N != nil:
+			// 保留数字的字符串形式，避免大整数转换为 float64 丢失精度
+			if _, err := strconv.ParseFloat(*v.N, 64); err != nil {
+				return nil, ErrInvalidCursor
+			}
+			offsetKey[k] = json.Number(*v.N)
+		default:
+			offsetKey[k] = v.B
+		}
+	}
+	return offsetKey, nil
+}
+
+// Query 从 token 开始查询一页，返回下一页的令牌，为空表示已读取完毕
+func (c *Cursor) Query(table Table, query *QueryOption, token string, results interface{}) (string, error) {
+	offsetKey, err := c.decode(token, query)
+	if err != nil {
+		return "", err
+	}
+	if err := table.Query(query, offsetKey, results); err != nil {
+		return "", err
+	}
+	return c.encode(offsetKey, query)
+}
+
+// Scan 从 token 开始扫描一页，返回下一页的令牌，为空表示已读取完毕
+func (c *Cursor) Scan(table Table, opt *ScanOption, token string, results interface{}) (string, error) {
+	if opt == nil {
+		opt = &ScanOption{}
+	}
+	offsetKey, err := c.decode(token, &opt.QueryOption, opt.Segment, opt.TotalSegments)
+	if err != nil {
+		return "", err
+	}
+	if err := table.Scan(opt, offsetKey, results); err != nil {
+		return "", err
+	}
+	return c.encode(offsetKey, &opt.QueryOption, opt.Segment, opt.TotalSegments)
+}
@@ -0,0 +1,84 @@
+package odm
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursor(t *testing.T) {
+	cursor := NewCursor([]byte("secret"), 0)
+	query := &QueryOption{
+		IndexName:   "AgeIndex",
+		KeyFilter:   "Author = :Author",
+		ValueParams: Map{":Author": "Tom"},
+		Limit:       10,
+	}
+	offsetKey := Map{"Author": "Tom", "Title": "Hello", "Age": json.Number("9007199254740993"), "Data": []byte{1, 2}}
+	token, err := cursor.Encode(offsetKey, query)
+	assert.NoError(t, err)
+	assert.NotContains(t, token, "Author")
+
+	// Limit 不影响令牌
+	q := *query
+	q.Limit = 20
+	key, err := cursor.Decode(token, &q)
+	assert.NoError(t, err)
+	assert.Equal(t, offsetKey, key)
+
+	// 数字解码为 json.Number，不会丢失精度
+	token1, err := cursor.Encode(Map{"Age": int64(9007199254740993)}, query)
+	assert.NoError(t, err)
+	key, err = cursor.Decode(token1, query)
+	assert.NoError(t, err)
+	assert.Equal(t, Map{"Age": json.Number("9007199254740993")}, key)
+
+	// 空令牌表示从头开始，读取完毕时返回空令牌
+	key, err = cursor.Decode("", query)
+	assert.NoError(t, err)
+	assert.Equal(t, Map{}, key)
+	token2, err := cursor.Encode(Map{}, query)
+	assert.NoError(t, err)
+	assert.Equal(t, "", token2)
+
+	t.Run("Invalid", func(t *testing.T) {
+		// 查询条件不同
+		q := *query
+		q.ValueParams = Map{":Author": "Jack"}
+		_, err := cursor.Decode(token, &q)
+		assert.Equal(t, ErrInvalidCursor, err)
+		q = *query
+		q.IndexName = ""
+		_, err = cursor.Decode(token, &q)
+		assert.Equal(t, ErrInvalidCursor, err)
+
+		// 密钥不同
+		_, err = NewCursor([]byte("other"), 0).Decode(token, query)
+		assert.Equal(t, ErrInvalidCursor, err)
+
+		// 被篡改
+		c := "A"
+		if token[20] == 'A' {
+			c = "B"
+		}
+		_, err = cursor.Decode(token[:20]+c+token[21:], query)
+		assert.Equal(t, ErrInvalidCursor, err)
+		_, err = cursor.Decode("not a token", query)
+		assert.Equal(t, ErrInvalidCursor, err)
+	})
+	t.Run("Expired", func(t *testing.T) {
+		cursor := NewCursor([]byte("secret"), time.Hour)
+		token, err := cursor.Encode(offsetKey, query)
+		assert.NoError(t, err)
+		_, err = cursor.Decode(token, query)
+		assert.NoError(t, err)
+
+		cursor.TTL = -time.Hour
+		token, err = cursor.Encode(offsetKey, query)
+		assert.NoError(t, err)
+		_, err = cursor.Decode(token, query)
+		assert.Equal(t, ErrCursorExpired, err)
+	})
+}
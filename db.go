@@ -1,5 +1,10 @@
 package odm
 
+import (
+	"context"
+	"errors"
+)
+
 // Config is Connection Configuration.
 type Config interface {
 }
@@ -7,6 +12,31 @@ type Config interface {
 // ODMDB 是对数据库的抽象
 type ODMDB struct {
 	DialectDB
+	ctx context.Context
+	// dialect 方言的名称，即 Dialect.GetName
+	dialect string
+}
+
+// WithContext 返回使用 ctx 的 ODMDB，通过它获得的 Table、事务和批量操作都会使用 ctx
+func (db *ODMDB) WithContext(ctx context.Context) *ODMDB {
+	return &ODMDB{
+		DialectDB: db.DialectDB.WithContext(ctx),
+		ctx:       ctx,
+		dialect:   db.dialect,
+	}
+}
+
+// DialectName 返回方言的名称，即 Dialect.GetName
+func (db *ODMDB) DialectName() string {
+	return db.dialect
+}
+
+// Context 返回当前使用的 context
+func (db *ODMDB) Context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+	return db.ctx
 }
 
 type Dialect interface {
@@ -15,6 +45,8 @@ type Dialect interface {
 }
 
 type DialectDB interface {
+	// WithContext 返回使用 ctx 发起请求的 DialectDB
+	WithContext(ctx context.Context) DialectDB
 	GetDialectTable(*TableMeta) Table
 	CreateTable(*TableMeta) error
 	CreateTableIfNotExists(*TableMeta) error
@@ -27,7 +59,11 @@ type DialectDB interface {
 	// TransactGetItem([]{get1, get2}, &result1, &result2)
 	TransactGetItems(gets []*TransactGet, results ...Model) error
 	// 一致性写，一起成功、一起失败
-	TransactWriteItems(writes []*TransactWrite) error
+	TransactWriteItems(writes []*TransactWrite, opt *TransactWriteOption) error
+	// SetConsumedCapacityHandler 设置接收每次请求消耗容量的 handler，为 nil 时不再接收
+	SetConsumedCapacityHandler(handler ConsumedCapacityHandler)
+	// SetSchemaPolicy 设置允许的表结构操作，WithContext 得到的副本不受之后的设置影响
+	SetSchemaPolicy(policy *SchemaPolicy)
 	Close()
 }
 
@@ -39,6 +75,10 @@ type TransactGet struct {
 	HashKey    interface{}
 	RangeKey   interface{}
 	Key        Map
+	// Found 不为 nil 时，执行后填充是否找到了 item。未找到的 item 不会修改对应的 result
+	Found *bool
+	// ReturnConsumedCapacity 同一个请求中取最详细的一项，见 ConsumedCapacityIndexes
+	ReturnConsumedCapacity string
 }
 
 type TransactWrite struct {
@@ -52,7 +92,9 @@ type TransactWrite struct {
 }
 
 type ConditionCheck struct {
-	TableName   string
+	TableName string
+	// 条件表达式，必填
+	Condition   string
 	NameParams  map[string]string
 	ValueParams Map
 	HashKey     interface{}
@@ -89,24 +131,46 @@ type Delete struct {
 	ReturnValuesOnConditionCheckFailure string
 }
 
+type TransactWriteOption struct {
+	// 幂等标识，10 分钟内使用相同 token 重复提交的事务只会执行一次
+	ClientRequestToken string
+	// ReturnConsumedCapacity 见 ConsumedCapacityIndexes
+	ReturnConsumedCapacity string
+}
+
 type BatchGet struct {
-	TableName  string
+	TableName string
+	// Meta 可选，用于将 KeyPairs 转换为主键，为 nil 时从数据库读取表结构
+	Meta       *TableMeta
 	Consistent bool
 	Select     string
 	NameParams map[string]string
 	Keys       []Map
+	// KeyPairs 以 HashKey、RangeKey 形式提供的主键，与 Keys 一起读取
+	KeyPairs []KeyPair
+	// ReturnConsumedCapacity 同一个请求中取最详细的一项，见 ConsumedCapacityIndexes
+	ReturnConsumedCapacity string
+}
+
+// KeyPair 由 HashKey 和可选的 RangeKey 组成的主键
+type KeyPair struct {
+	HashKey  interface{}
+	RangeKey interface{}
 }
 
 type BatchWrite struct {
 	TableName  string
 	PutItems   interface{}
 	DeleteKeys []Map
+	// ReturnConsumedCapacity 同一个请求中取最详细的一项，见 ConsumedCapacityIndexes
+	ReturnConsumedCapacity string
 }
 
+// ResetTable 删除并重新创建 model 对应的表，需要 SchemaPolicy 允许删除和创建该表
 func (db *ODMDB) ResetTable(model Model) (Table, error) {
 	metaInfo := GetModelMeta(model)
-	if IsDropTableEnabled() {
-		db.DropTable(metaInfo.TableName)
+	if err := db.DropTable(metaInfo.TableName); err != nil && !errors.Is(err, ErrTableNotFound) {
+		return nil, err
 	}
 	err := db.CreateTableIfNotExists(metaInfo)
 	if err != nil {
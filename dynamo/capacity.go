@@ -0,0 +1,128 @@
+package dynamo
+
+import (
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// SetConsumedCapacityHandler 设置接收每次请求消耗容量的 handler，WithContext 得到的 DB 和 Table 共享该设置
+func (db *DB) SetConsumedCapacityHandler(handler odm.ConsumedCapacityHandler) {
+	db.capacity.Store(handler)
+}
+
+func (db *DB) capacityHandler() odm.ConsumedCapacityHandler {
+	if db.capacity == nil {
+		return nil
+	}
+	handler, _ := db.capacity.Load().(odm.ConsumedCapacityHandler)
+	return handler
+}
+
+// capacityLevels ReturnConsumedCapacity 的详细程度，多个设置合并到同一个请求时取最详细的
+var capacityLevels = map[string]int{
+	"":                          0,
+	odm.ConsumedCapacityNone:    1,
+	odm.ConsumedCapacityTotal:   2,
+	odm.ConsumedCapacityIndexes: 3,
+}
+
+// mergeReturnConsumedCapacity 返回 levels 中最详细的一项
+func mergeReturnConsumedCapacity(levels ...string) string {
+	merged := ""
+	for _, level := range levels {
+		if capacityLevels[level] > capacityLevels[merged] {
+			merged = level
+		}
+	}
+	return merged
+}
+
+// returnConsumedCapacity 请求使用的 ReturnConsumedCapacity，
+// 未设置时有 handler 或者经由 odm.Interceptor 发起的请求为 INDEXES
+func (db *DB) returnConsumedCapacity(levels ...string) *string {
+	level := mergeReturnConsumedCapacity(levels...)
+	if level == "" {
+		if db.capacityHandler() == nil && odm.OperationFromContext(db.Context()) == nil {
+			return nil
+		}
+		level = odm.ConsumedCapacityIndexes
+	}
+	return aws.String(level)
+}
+
+// writeCapacity 返回 opt 中的 ReturnConsumedCapacity，opt 可以为 nil
+func writeCapacity(opt *odm.WriteOption) string {
+	if opt == nil {
+		return ""
+	}
+	return opt.ReturnConsumedCapacity
+}
+
+// getCapacity 返回 opt 中的 ReturnConsumedCapacity，opt 可以为 nil
+func getCapacity(opt *odm.GetOption) string {
+	if opt == nil {
+		return ""
+	}
+	return opt.ReturnConsumedCapacity
+}
+
+// reportConsumedCapacity 将消耗的容量交给 handler，并记录到 ctx 所属的 odm.Operation
+func (db *DB) reportConsumedCapacity(operation string, capacities ...*dynamodb.ConsumedCapacity) {
+	handler := db.capacityHandler()
+	op := odm.OperationFromContext(db.Context())
+	if handler == nil && op == nil {
+		return
+	}
+	converted := []*odm.ConsumedCapacity{}
+	for _, c := range capacities {
+		if c != nil {
+			converted = append(converted, convertConsumedCapacity(c))
+		}
+	}
+	if len(converted) == 0 {
+		return
+	}
+	if op != nil {
+		op.AddConsumedCapacity(converted...)
+	}
+	if handler != nil {
+		handler(db.Context(), operation, converted)
+	}
+}
+
+func convertCapacity(c *dynamodb.Capacity) *odm.Capacity {
+	if c == nil {
+		return nil
+	}
+	return &odm.Capacity{
+		CapacityUnits:      aws.Float64Value(c.CapacityUnits),
+		ReadCapacityUnits:  aws.Float64Value(c.ReadCapacityUnits),
+		WriteCapacityUnits: aws.Float64Value(c.WriteCapacityUnits),
+	}
+}
+
+func convertIndexCapacity(indexes map[string]*dynamodb.Capacity) map[string]*odm.Capacity {
+	if len(indexes) == 0 {
+		return nil
+	}
+	converted := map[string]*odm.Capacity{}
+	for name, c := range indexes {
+		converted[name] = convertCapacity(c)
+	}
+	return converted
+}
+
+func convertConsumedCapacity(c *dynamodb.ConsumedCapacity) *odm.ConsumedCapacity {
+	return &odm.ConsumedCapacity{
+		TableName: aws.StringValue(c.TableName),
+		Capacity: odm.Capacity{
+			CapacityUnits:      aws.Float64Value(c.CapacityUnits),
+			ReadCapacityUnits:  aws.Float64Value(c.ReadCapacityUnits),
+			WriteCapacityUnits: aws.Float64Value(c.WriteCapacityUnits),
+		},
+		Table:                  convertCapacity(c.Table),
+		GlobalSecondaryIndexes: convertIndexCapacity(c.GlobalSecondaryIndexes),
+		LocalSecondaryIndexes:  convertIndexCapacity(c.LocalSecondaryIndexes),
+	}
+}
@@ -0,0 +1,77 @@
+package dynamo
+
+import (
+	"context"
+	"testing"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeReturnConsumedCapacity(t *testing.T) {
+	assert.Equal(t, "", mergeReturnConsumedCapacity())
+	assert.Equal(t, odm.ConsumedCapacityNone, mergeReturnConsumedCapacity("", odm.ConsumedCapacityNone))
+	assert.Equal(t, odm.ConsumedCapacityIndexes, mergeReturnConsumedCapacity(odm.ConsumedCapacityTotal, odm.ConsumedCapacityIndexes, odm.ConsumedCapacityNone))
+
+	db := &DB{}
+	assert.Nil(t, db.returnConsumedCapacity())
+	assert.Equal(t, odm.ConsumedCapacityTotal, aws.StringValue(db.returnConsumedCapacity(odm.ConsumedCapacityTotal)))
+}
+
+func TestConvertConsumedCapacity(t *testing.T) {
+	c := convertConsumedCapacity(&dynamodb.ConsumedCapacity{
+		TableName:     aws.String("member"),
+		CapacityUnits: aws.Float64(1.5),
+		Table:         &dynamodb.Capacity{CapacityUnits: aws.Float64(1)},
+		GlobalSecondaryIndexes: map[string]*dynamodb.Capacity{
+			"NickIndex": {CapacityUnits: aws.Float64(0.5)},
+		},
+	})
+	assert.Equal(t, &odm.ConsumedCapacity{
+		TableName:              "member",
+		Capacity:               odm.Capacity{CapacityUnits: 1.5},
+		Table:                  &odm.Capacity{CapacityUnits: 1},
+		GlobalSecondaryIndexes: map[string]*odm.Capacity{"NickIndex": {CapacityUnits: 0.5}},
+	}, c)
+}
+
+func TestDB_ConsumedCapacity(t *testing.T) {
+	resetDB(t)
+	db, err := odm.Open("dynamo", dbpath)
+	assert.NoError(t, err)
+	operations := []string{}
+	capacities := []*odm.ConsumedCapacity{}
+	db.SetConsumedCapacityHandler(func(ctx context.Context, operation string, consumed []*odm.ConsumedCapacity) {
+		operations = append(operations, operation)
+		capacities = append(capacities, consumed...)
+	})
+	table := db.Table(&Book{})
+	assert.NoError(t, table.PutItem(&Book{Author: "Tom", Title: "Hello"}, nil, nil))
+	assert.NoError(t, table.GetItem("Tom", "Hello", &odm.GetOption{ReturnConsumedCapacity: odm.ConsumedCapacityTotal}, &Book{}))
+	// NONE 时不返回
+	assert.NoError(t, table.GetItem("Tom", "Hello", &odm.GetOption{ReturnConsumedCapacity: odm.ConsumedCapacityNone}, &Book{}))
+	assert.Equal(t, []string{"PutItem", "GetItem"}, operations)
+	require.Len(t, capacities, 2)
+	assert.Equal(t, "book", capacities[0].TableName)
+	assert.NotNil(t, capacities[0].Table)
+	assert.Nil(t, capacities[1].Table)
+	assert.True(t, capacities[1].CapacityUnits > 0)
+
+	db.SetConsumedCapacityHandler(nil)
+	assert.NoError(t, table.PutItem(&Book{Author: "Tom", Title: "Hello"}, nil, nil))
+	assert.Len(t, operations, 2)
+
+	// 经由 Interceptor 的请求将消耗的容量记录到 Operation
+	var consumed []*odm.ConsumedCapacity
+	db.Use(func(ctx context.Context, op *odm.Operation, next func(context.Context) error) error {
+		err := next(ctx)
+		consumed = op.ConsumedCapacity
+		return err
+	})
+	assert.NoError(t, db.Table(&Book{}).GetItem("Tom", "Hello", nil, &Book{}))
+	require.Len(t, consumed, 1)
+	assert.Equal(t, "book", consumed[0].TableName)
+}
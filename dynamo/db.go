@@ -1,14 +1,18 @@
 package dynamo
 
 import (
+	"context"
 	"errors"
-	"strings"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 
 	"git.devops.com/go/odm"
+	"git.devops.com/go/odm/codec"
+	"git.devops.com/go/odm/expression"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
@@ -25,107 +29,112 @@ type dynamoDialect struct {
 }
 
 func (d *dynamoDialect) Open(connectString string) (odm.DialectDB, error) {
-	cfg, err := ParseConnectString(connectString)
+	cfg, err := ParseConfig(connectString)
 	if err != nil {
 		return nil, err
 	}
-	return OpenDB(cfg)
+	return OpenDBWithConfig(cfg)
 }
 
 func (d *dynamoDialect) GetName() string {
 	return dbName
 }
 
-func ParseConnectString(connectString string) (*aws.Config, error) {
-	parts := strings.Split(connectString, ";")
-	cfg := &aws.Config{}
-	var accessKey, secretKey, token string
-	for _, part := range parts {
-		part = strings.Trim(part, " ")
-		if part != "" {
-			kv := strings.SplitN(part, "=", 2)
-			v := kv[1]
-			switch strings.ToLower(kv[0]) {
-			case "accesskey":
-				accessKey = v
-				break
-			case "secretkey":
-				secretKey = v
-			case "region":
-				cfg.Region = aws.String(v)
-			case "token":
-				token = v
-				break
-			case "endpoint":
-				cfg.Endpoint = aws.String(v)
-				break
-			default:
-			}
-		}
-	}
-	if accessKey != "" && secretKey != "" {
-		cfg.Credentials = credentials.NewStaticCredentials(accessKey, secretKey, token)
-	}
-	return cfg, nil
-}
-
-// Open dynamodb client
-func openConnection(cfg *aws.Config) (*dynamodb.DynamoDB, error) {
-	// Initialize a session in us-west-2 that the SDK will use to load
-	// credentials from the shared credentials file ~/.aws/credentials.
-	sess, err := session.NewSession(cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create DynamoDB client
-	svc := dynamodb.New(sess)
-	return svc, nil
+// OpenDB 以 AWS 的底层配置打开数据库，不允许创建和删除表，见 DB.SetSchemaPolicy
+func OpenDB(cfg *aws.Config) (*DB, error) {
+	return OpenDBWithConfig(&Config{AWS: cfg})
 }
 
-func OpenDB(cfg *aws.Config) (*DB, error) {
+// OpenDBWithConfig 以 ParseConfig 得到的配置打开数据库
+func OpenDBWithConfig(cfg *Config) (*DB, error) {
 	conn, err := openConnection(cfg)
 	if err != nil {
 		return nil, err
 	}
 	db := &DB{
-		conn:                conn,
-		enableTableCreation: *cfg.Region == "localhost",
-		enableTableDeletion: *cfg.Region == "localhost",
-		tableMap:            make(map[string]*Table),
-		tableMetaMap:        make(map[string]*odm.TableMeta),
+		conn:         conn,
+		schema:       cfg.Schema,
+		tableMap:     make(map[string]*Table),
+		tableMetaMap: make(map[string]*odm.TableMeta),
+		metaMu:       &sync.RWMutex{},
+		capacity:     &atomic.Value{},
+		retry:        cfg.Retry,
 	}
 	return db, nil
 }
 
 type DB struct {
 	conn *dynamodb.DynamoDB
-	// if this is true, then auto create table if not exists.
-	enableTableCreation bool
-	enableTableDeletion bool
+	// schema 允许的表结构操作，为 nil 时不允许创建和删除表
+	schema *odm.SchemaPolicy
 	// cache for Describe table
 	// TODO: what if table changed while running?
 	tableMetaMap map[string]*odm.TableMeta
+	// metaMu 保护 tableMetaMap，WithContext 得到的 DB 共享
+	metaMu *sync.RWMutex
 	// cache for Table
 	tableMap map[string]*Table
+	// ctx 用于本 DB 发起的全部请求，为 nil 时使用 context.Background()
+	ctx context.Context
+	// capacity 存储 odm.ConsumedCapacityHandler，WithContext 得到的 DB 共享
+	capacity *atomic.Value
+	// retry 连接串中配置的重试策略
+	retry *odm.RetryPolicy
 }
 
-// DropTable only allowed on localhost
+// WithContext 返回使用 ctx 发起请求的 DB，与原 DB 共享连接和缓存
+func (db *DB) WithContext(ctx context.Context) odm.DialectDB {
+	return db.withContext(ctx)
+}
+
+func (db *DB) withContext(ctx context.Context) *DB {
+	copied := *db
+	copied.ctx = ctx
+	return &copied
+}
+
+// RetryPolicy 返回连接串中配置的重试策略，实现 odm.RetryConfigurer
+func (db *DB) RetryPolicy() *odm.RetryPolicy {
+	return db.retry
+}
+
+// DisableBuiltinRetry 关闭 AWS SDK 自身的重试，由 odm.ODMDB 按 RetryPolicy 统一重试
+func (db *DB) DisableBuiltinRetry() {
+	db.conn.Retryer = client.NoOpRetryer{}
+}
+
+// Context 返回当前请求使用的 context
+func (db *DB) Context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+	return db.ctx
+}
+
+// SetSchemaPolicy 设置允许的表结构操作，为 nil 时不允许创建和删除表
+func (db *DB) SetSchemaPolicy(policy *odm.SchemaPolicy) {
+	db.schema = policy
+}
+
+// DropTable SchemaPolicy 不允许时返回 odm.ErrSchemaNotAllowed
 func (db *DB) DropTable(tableName string) error {
-	if !db.enableTableDeletion {
-		panic("DropTable is not allowed")
+	if err := db.schema.CheckDropTable(tableName); err != nil {
+		return err
 	}
 	conn := db.GetConn()
-	_, err := conn.DeleteTable(&dynamodb.DeleteTableInput{
+	_, err := conn.DeleteTableWithContext(db.Context(), &dynamodb.DeleteTableInput{
 		TableName: aws.String(tableName),
 	})
-	return err
+	db.metaMu.Lock()
+	delete(db.tableMetaMap, tableName)
+	db.metaMu.Unlock()
+	return convertError(err)
 }
 
 func (db *DB) CreateTableIfNotExists(meta *odm.TableMeta) error {
 	_meta, err := db.GetTableMeta(meta.TableName)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeResourceNotFoundException {
+		if !errors.Is(err, odm.ErrTableNotFound) {
 			return err
 		}
 	}
@@ -141,96 +150,136 @@ func (db *DB) getFieldName(f *odm.FieldDefine) string {
 }
 
 func (db *DB) CreateTable(tableMeta *odm.TableMeta) error {
+	if err := db.schema.CheckCreateTable(tableMeta.TableName); err != nil {
+		return err
+	}
 	conn := db.GetConn()
-	// Key definition.
-	keySchema := []*dynamodb.KeySchemaElement{
-		&dynamodb.KeySchemaElement{
-			AttributeName: aws.String(db.getFieldName(tableMeta.PK)),
-			KeyType:       aws.String("HASH"),
-		},
-	}
-	// AttributeDefinitions
-	attrs := []*dynamodb.AttributeDefinition{
-		&dynamodb.AttributeDefinition{
-			AttributeName: aws.String(db.getFieldName(tableMeta.PK)),
-			AttributeType: aws.String(tableMeta.PK.Type),
-		},
-	}
-	if tableMeta.SK != nil {
-		keySchema = append(keySchema, &dynamodb.KeySchemaElement{
-			AttributeName: aws.String(db.getFieldName(tableMeta.SK)),
-			KeyType:       aws.String("RANGE"),
-		})
-		attrs = append(attrs, &dynamodb.AttributeDefinition{
-			AttributeName: aws.String(db.getFieldName(tableMeta.SK)),
-			AttributeType: aws.String(tableMeta.SK.Type),
+	// AttributeDefinitions，表和索引的主键都需要定义，且不能重复
+	attrs := []*dynamodb.AttributeDefinition{}
+	defined := map[string]bool{}
+	keySchema := func(pk *odm.FieldDefine, sk *odm.FieldDefine) []*dynamodb.KeySchemaElement {
+		schema := []*dynamodb.KeySchemaElement{}
+		for _, key := range []struct {
+			field   *odm.FieldDefine
+			keyType string
+		}{{pk, "HASH"}, {sk, "RANGE"}} {
+			if key.field == nil {
+				continue
+			}
+			name := db.getFieldName(key.field)
+			schema = append(schema, &dynamodb.KeySchemaElement{
+				AttributeName: aws.String(name),
+				KeyType:       aws.String(key.keyType),
+			})
+			if !defined[name] {
+				defined[name] = true
+				attrs = append(attrs, &dynamodb.AttributeDefinition{
+					AttributeName: aws.String(name),
+					AttributeType: aws.String(key.field.Type),
+				})
+			}
+		}
+		return schema
+	}
+	if tableMeta.PK == nil {
+		return errors.New("PK is required to create table " + tableMeta.TableName)
+	}
+	input := &dynamodb.CreateTableInput{
+		TableName: aws.String(tableMeta.TableName),
+		KeySchema: keySchema(tableMeta.PK, tableMeta.SK),
+		// 按需计费的表和索引不能设置 ProvisionedThroughput
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	}
+	for _, index := range tableMeta.Indexes {
+		projection := &dynamodb.Projection{
+			ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+		}
+		if index.Global {
+			if index.PK == nil {
+				return errors.New("PK is required to create global secondary index " + index.IndexName)
+			}
+			input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndex{
+				IndexName:  aws.String(index.IndexName),
+				KeySchema:  keySchema(index.PK, index.SK),
+				Projection: projection,
+			})
+			continue
+		}
+		if index.SK == nil {
+			return errors.New("SK is required to create local secondary index " + index.IndexName)
+		}
+		input.LocalSecondaryIndexes = append(input.LocalSecondaryIndexes, &dynamodb.LocalSecondaryIndex{
+			IndexName:  aws.String(index.IndexName),
+			KeySchema:  keySchema(tableMeta.PK, index.SK),
+			Projection: projection,
 		})
 	}
-	// for _, f := range tableMeta.Fields {
-	// 	attrs = append(attrs, &dynamodb.AttributeDefinition{
-	// 		AttributeName: aws.String(db.getFieldName(f)),
-	// 		AttributeType: aws.String(f.Type),
-	// 	})
-	// }
-	// TODO: GSI
-	// TODO: LSI
-	out, err := conn.CreateTable(&dynamodb.CreateTableInput{
-		TableName:   aws.String(tableMeta.TableName),
-		KeySchema:   keySchema,
-		BillingMode: aws.String("PAY_PER_REQUEST"), // PAY_PER_REQUEST, PROVISIONED
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(1000),
-			WriteCapacityUnits: aws.Int64(1000),
-		},
-		// GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{},
-		// LocalSecondaryIndexes:  []*dynamodb.LocalSecondaryIndex{},
-		AttributeDefinitions: attrs,
-	})
+	input.AttributeDefinitions = attrs
+	out, err := conn.CreateTableWithContext(db.Context(), input)
 	if err == nil && out != nil && out.TableDescription != nil {
-		db.tableMetaMap[tableMeta.TableName] = db.updateTableDescription(out.TableDescription)
+		db.updateTableDescription(out.TableDescription)
 	}
-	return err
+	return convertError(err)
+}
+
+// keySchemaFields 将 KeySchema 转换为 PK、SK 的字段定义
+func keySchemaFields(keySchema []*dynamodb.KeySchemaElement) (pk *odm.FieldDefine, sk *odm.FieldDefine) {
+	for _, key := range keySchema {
+		field := &odm.FieldDefine{
+			SchemaFieldName: map[string]string{
+				dbName: *key.AttributeName,
+			},
+		}
+		if *key.KeyType == "HASH" {
+			pk = field
+		} else if *key.KeyType == "RANGE" {
+			sk = field
+		}
+	}
+	return pk, sk
 }
 
 func (db *DB) updateTableDescription(tableDesc *dynamodb.TableDescription) *odm.TableMeta {
 	meta := &odm.TableMeta{
 		TableName: *tableDesc.TableName,
 	}
-	for _, key := range tableDesc.KeySchema {
-		if *key.KeyType == "HASH" {
-			meta.PK = &odm.FieldDefine{
-				SchemaFieldName: map[string]string{
-					dbName: *key.AttributeName,
-				},
-			}
-		} else if *key.KeyType == "RANGE" {
-			meta.SK = &odm.FieldDefine{
-				SchemaFieldName: map[string]string{
-					dbName: *key.AttributeName,
-				},
-			}
+	meta.PK, meta.SK = keySchemaFields(tableDesc.KeySchema)
+	for _, gsi := range tableDesc.GlobalSecondaryIndexes {
+		index := &odm.IndexMeta{
+			IndexName: *gsi.IndexName,
+			Global:    true,
+		}
+		index.PK, index.SK = keySchemaFields(gsi.KeySchema)
+		meta.Indexes = append(meta.Indexes, index)
+	}
+	for _, lsi := range tableDesc.LocalSecondaryIndexes {
+		index := &odm.IndexMeta{
+			IndexName: *lsi.IndexName,
 		}
+		index.PK, index.SK = keySchemaFields(lsi.KeySchema)
+		meta.Indexes = append(meta.Indexes, index)
 	}
+	db.metaMu.Lock()
 	db.tableMetaMap[*tableDesc.TableName] = meta
-	// result.Table.LocalSecondaryIndexes
-	// result.Table.GlobalSecondaryIndexes
+	db.metaMu.Unlock()
 	return meta
 }
 
 func (db *DB) GetTableMeta(tableName string) (*odm.TableMeta, error) {
+	db.metaMu.RLock()
 	meta := db.tableMetaMap[tableName]
+	db.metaMu.RUnlock()
 	if meta != nil {
 		return meta, nil
 	}
 	conn := db.GetConn()
-	result, err := conn.DescribeTable(&dynamodb.DescribeTableInput{
+	result, err := conn.DescribeTableWithContext(db.Context(), &dynamodb.DescribeTableInput{
 		TableName: aws.String(tableName),
 	})
 	if err == nil && result != nil && result.Table != nil {
 		meta = db.updateTableDescription(result.Table)
-		db.tableMetaMap[tableName] = meta
 	}
-	return meta, err
+	return meta, convertError(err)
 }
 
 func (db *DB) key(tableName string, hashKey interface{}, rangeKey interface{}) (map[string]*dynamodb.AttributeValue, error) {
@@ -267,135 +316,552 @@ func (db *DB) Close() {
 	// Nothing to do.
 }
 
+// batchGetLimit 单次 BatchGetItem 请求最多包含的主键数
+const batchGetLimit = 100
+
+// batchGetConcurrency 同时执行的 BatchGetItem 请求数
+const batchGetConcurrency = 8
+
+type getRequest struct {
+	// options 中的下标
+	option int
+	key    map[string]*dynamodb.AttributeValue
+}
+
+// batchGetKeys 合并 Keys 和 KeyPairs，KeyPairs 根据 Meta 或表结构转换为主键
+func (db *DB) batchGetKeys(opt *odm.BatchGet) ([]map[string]*dynamodb.AttributeValue, error) {
+	keys := []map[string]*dynamodb.AttributeValue{}
+	for _, key := range opt.Keys {
+		keyMap, err := dynamodbattribute.MarshalMap(key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keyMap)
+	}
+	if len(opt.KeyPairs) == 0 {
+		return keys, nil
+	}
+	meta := opt.Meta
+	if meta == nil || meta.PK == nil {
+		var err error
+		if meta, err = db.GetTableMeta(opt.TableName); err != nil {
+			return nil, err
+		}
+	}
+	table := &Table{TableMeta: *meta, db: db}
+	for _, pair := range opt.KeyPairs {
+		keyMap, err := table.key(pair.HashKey, pair.RangeKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keyMap)
+	}
+	return keys, nil
+}
+
+// BatchGetItem 按每 100 个主键拆分请求，最多 batchGetConcurrency 个请求并发执行，
+// 读到的 item 按表名填充到对应的 results[i]，顺序不保证与主键一致。
+// DynamoDB 返回的 UnprocessedKeys 会追加到 unprocessedItems，BatchGetItem 不会重试，需要重试时使用 odm.BatchGetItemWithRetry。
+// 任一请求出错时不再发送剩余的请求，直接返回错误，results 和 unprocessedItems 不会被修改
 func (db *DB) BatchGetItem(options []*odm.BatchGet, unprocessedItems *[]*odm.BatchGet, results ...interface{}) error {
-	input := &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]*dynamodb.KeysAndAttributes{},
+	if len(results) < len(options) {
+		return fmt.Errorf("BatchGetItem requires a result for each option, %d options but %d results", len(options), len(results))
 	}
-	var resultsMap map[string]interface{}
+	index := map[string]int{}
+	attributes := make([]*dynamodb.KeysAndAttributes, len(options))
+	requests := []*getRequest{}
+	levels := make([]string, len(options))
 	for i, opt := range options {
+		levels[i] = opt.ReturnConsumedCapacity
 		if opt.TableName == "" {
-			panic("BatchGetItem TableName is required, but got nil.")
+			return errors.New("BatchGetItem TableName is required")
 		}
-		if input.RequestItems[opt.TableName] != nil {
-			panic("BatchGetItem options TableName <" + opt.TableName + "> duplicated. ")
+		if _, ok := index[opt.TableName]; ok {
+			return errors.New("BatchGetItem options TableName <" + opt.TableName + "> duplicated")
 		}
-		if opt.Keys == nil || len(opt.Keys) == 0 {
-			continue
+		index[opt.TableName] = i
+		err := validateExpressions(&expression.Expressions{
+			Projection: opt.Select,
+			Names:      opt.NameParams,
+		})
+		if err != nil {
+			return err
 		}
-		optIn := &dynamodb.KeysAndAttributes{}
+		attrs := &dynamodb.KeysAndAttributes{}
 		if opt.Consistent {
-			optIn.ConsistentRead = aws.Bool(opt.Consistent)
+			attrs.ConsistentRead = aws.Bool(opt.Consistent)
 		}
 		if opt.Select != "" {
-			optIn.ProjectionExpression = aws.String(opt.Select)
+			attrs.ProjectionExpression = aws.String(opt.Select)
 		}
-		if opt.NameParams != nil && len(opt.NameParams) > 0 {
-			convertAttributeNames(opt.NameParams, optIn.ExpressionAttributeNames)
+		if len(opt.NameParams) > 0 {
+			attrs.ExpressionAttributeNames = make(map[string]*string)
+			convertAttributeNames(opt.NameParams, attrs.ExpressionAttributeNames)
 		}
-		for _, key := range opt.Keys {
-			keyMap, err := dynamodbattribute.MarshalMap(key)
+		attributes[i] = attrs
+		keys, err := db.batchGetKeys(opt)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			requests = append(requests, &getRequest{option: i, key: key})
+		}
+	}
+
+	returnCapacity := db.returnConsumedCapacity(levels...)
+	responses := make([][]map[string]*dynamodb.AttributeValue, len(options))
+	unprocessed := []*getRequest{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	sem := make(chan struct{}, batchGetConcurrency)
+	for start := 0; start < len(requests); start += batchGetLimit {
+		end := start + batchGetLimit
+		if end > len(requests) {
+			end = len(requests)
+		}
+		sem <- struct{}{}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(chunk []*getRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			input := &dynamodb.BatchGetItemInput{
+				RequestItems:           map[string]*dynamodb.KeysAndAttributes{},
+				ReturnConsumedCapacity: returnCapacity,
+			}
+			for _, req := range chunk {
+				tableName := options[req.option].TableName
+				attrs := input.RequestItems[tableName]
+				if attrs == nil {
+					copied := *attributes[req.option]
+					attrs = &copied
+					input.RequestItems[tableName] = attrs
+				}
+				attrs.Keys = append(attrs.Keys, req.key)
+			}
+			out, err := db.GetConn().BatchGetItemWithContext(db.Context(), input)
+			err = convertError(err)
+			if err == nil {
+				db.reportConsumedCapacity("BatchGetItem", out.ConsumedCapacity...)
+			}
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
-				return err
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for tableName, items := range out.Responses {
+				i := index[tableName]
+				responses[i] = append(responses[i], items...)
 			}
-			optIn.Keys = append(optIn.Keys, keyMap)
+			for tableName, attrs := range out.UnprocessedKeys {
+				for _, key := range attrs.Keys {
+					unprocessed = append(unprocessed, &getRequest{option: index[tableName], key: key})
+				}
+			}
+		}(requests[start:end])
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	for i := range options {
+		items := responses[i]
+		if items == nil {
+			items = []map[string]*dynamodb.AttributeValue{}
+		}
+		if err := codec.UnmarshalList(items, results[i]); err != nil {
+			return err
 		}
-		input.RequestItems[opt.TableName] = optIn
-		resultsMap[opt.TableName] = results[i]
 	}
-	out, err := db.GetConn().BatchGetItem(input)
-	// Handle output
-	for tableName, items := range out.Responses {
-		err = dynamodbattribute.UnmarshalListOfMaps(items, resultsMap[tableName])
+	if unprocessedItems != nil && len(unprocessed) > 0 {
+		gets, err := revertGetRequests(options, unprocessed)
 		if err != nil {
 			return err
 		}
+		*unprocessedItems = append(*unprocessedItems, gets...)
 	}
-	// Handle UnprocessedKyes
-	// 测试时以 input.RequestItem 代替
-	for tableName, requestItem := range out.UnprocessedKeys {
-		rawItem := odm.BatchGet{
-			TableName:  tableName,
-			NameParams: map[string]string{},
-			Keys:       []odm.Map{},
-		}
-		if requestItem.ConsistentRead != nil {
-			rawItem.Consistent = *requestItem.ConsistentRead
+	return nil
+}
+
+// revertGetRequests 将未处理的主键转换为 BatchGet，保留原有的读取参数
+func revertGetRequests(options []*odm.BatchGet, requests []*getRequest) ([]*odm.BatchGet, error) {
+	gets := []*odm.BatchGet{}
+	byOption := map[int]*odm.BatchGet{}
+	for _, req := range requests {
+		get := byOption[req.option]
+		if get == nil {
+			opt := options[req.option]
+			get = &odm.BatchGet{
+				TableName:              opt.TableName,
+				Meta:                   opt.Meta,
+				Consistent:             opt.Consistent,
+				Select:                 opt.Select,
+				NameParams:             opt.NameParams,
+				Keys:                   []odm.Map{},
+				ReturnConsumedCapacity: opt.ReturnConsumedCapacity,
+			}
+			byOption[req.option] = get
+			gets = append(gets, get)
 		}
-		if requestItem.ExpressionAttributeNames != nil {
-			revertAttributeNames(rawItem.NameParams, requestItem.ExpressionAttributeNames)
+		key := make(odm.Map)
+		if err := dynamodbattribute.UnmarshalMap(req.key, &key); err != nil {
+			return nil, err
 		}
-		if requestItem.ProjectionExpression != nil {
-			rawItem.Select = *requestItem.ProjectionExpression
+		get.Keys = append(get.Keys, key)
+	}
+	return gets, nil
+}
+
+// batchWriteLimit 单次 BatchWriteItem 请求最多包含的写操作数
+const batchWriteLimit = 25
+
+// RawItem 已经是 DynamoDB 数据模型的 item。BatchWriteItem 返回的未处理数据使用 RawItem，
+// 重试时原样写入，不会因为转换丢失数字精度或集合类型
+type RawItem map[string]*dynamodb.AttributeValue
+
+// MarshalDynamoDBAttributeValue implements dynamodbattribute.Marshaler
+func (item RawItem) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	av.M = item
+	return nil
+}
+
+type writeRequest struct {
+	tableName string
+	request   *dynamodb.WriteRequest
+}
+
+// writeRequests 将 BatchWrite 展开为单个写操作
+func writeRequests(options []*odm.BatchWrite) ([]*writeRequest, error) {
+	requests := []*writeRequest{}
+	for _, opt := range options {
+		if opt.TableName == "" {
+			return nil, errors.New("BatchWriteItem TableName is required")
 		}
-		if requestItem.Keys != nil {
-			for _, keyMap := range requestItem.Keys {
-				key := make(odm.Map)
-				err = dynamodbattribute.UnmarshalMap(keyMap, key)
+		if opt.PutItems != nil {
+			items := reflect.ValueOf(opt.PutItems)
+			if items.Kind() == reflect.Ptr {
+				items = items.Elem()
+			}
+			if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+				return nil, fmt.Errorf("BatchWriteItem PutItems should be a slice, but got %T", opt.PutItems)
+			}
+			for i := 0; i < items.Len(); i++ {
+				av, err := codec.Marshal(items.Index(i).Interface())
 				if err != nil {
-					return err
+					return nil, err
 				}
-				rawItem.Keys = append(rawItem.Keys, key)
+				requests = append(requests, &writeRequest{
+					tableName: opt.TableName,
+					request:   &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: av}},
+				})
+			}
+		}
+		for _, key := range opt.DeleteKeys {
+			keyMap, err := dynamodbattribute.MarshalMap(key)
+			if err != nil {
+				return nil, err
 			}
+			requests = append(requests, &writeRequest{
+				tableName: opt.TableName,
+				request:   &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: keyMap}},
+			})
 		}
-		*unprocessedItems = append(*unprocessedItems, &rawItem)
 	}
-	return err
+	return requests, nil
 }
 
+// revertWriteRequests 将未处理的写操作转换为 BatchWrite，按表名出现的顺序排列
+func revertWriteRequests(requests []*writeRequest) ([]*odm.BatchWrite, error) {
+	writes := []*odm.BatchWrite{}
+	tables := map[string]*odm.BatchWrite{}
+	for _, req := range requests {
+		write := tables[req.tableName]
+		if write == nil {
+			write = &odm.BatchWrite{TableName: req.tableName}
+			tables[req.tableName] = write
+			writes = append(writes, write)
+		}
+		if req.request.PutRequest != nil {
+			items, _ := write.PutItems.([]RawItem)
+			write.PutItems = append(items, RawItem(req.request.PutRequest.Item))
+		}
+		if req.request.DeleteRequest != nil {
+			key := make(odm.Map)
+			if err := dynamodbattribute.UnmarshalMap(req.request.DeleteRequest.Key, &key); err != nil {
+				return nil, err
+			}
+			write.DeleteKeys = append(write.DeleteKeys, key)
+		}
+	}
+	return writes, nil
+}
+
+// BatchWriteItem 按每 25 个写操作拆分请求。未处理的数据会追加到 unprocessedItems，
+// 某个请求出错时，该请求及之后的数据也会作为未处理数据返回
 func (db *DB) BatchWriteItem(options []*odm.BatchWrite, unprocessedItems *[]*odm.BatchWrite) error {
-	panic("not implemented") // TODO: Implement
+	requests, err := writeRequests(options)
+	if err != nil {
+		return err
+	}
+	levels := make([]string, len(options))
+	for i, opt := range options {
+		levels[i] = opt.ReturnConsumedCapacity
+	}
+	level := mergeReturnConsumedCapacity(levels...)
+	unprocessed := []*writeRequest{}
+	for start := 0; start < len(requests); start += batchWriteLimit {
+		end := start + batchWriteLimit
+		if end > len(requests) {
+			end = len(requests)
+		}
+		input := &dynamodb.BatchWriteItemInput{
+			RequestItems:           map[string][]*dynamodb.WriteRequest{},
+			ReturnConsumedCapacity: db.returnConsumedCapacity(level),
+		}
+		for _, req := range requests[start:end] {
+			input.RequestItems[req.tableName] = append(input.RequestItems[req.tableName], req.request)
+		}
+		var out *dynamodb.BatchWriteItemOutput
+		out, err = db.GetConn().BatchWriteItemWithContext(db.Context(), input)
+		err = convertError(err)
+		if err != nil {
+			unprocessed = append(unprocessed, requests[start:]...)
+			break
+		}
+		db.reportConsumedCapacity("BatchWriteItem", out.ConsumedCapacity...)
+		for tableName, items := range out.UnprocessedItems {
+			for _, item := range items {
+				unprocessed = append(unprocessed, &writeRequest{tableName: tableName, request: item})
+			}
+		}
+	}
+	if unprocessedItems != nil && len(unprocessed) > 0 {
+		writes, rerr := revertWriteRequests(unprocessed)
+		if rerr != nil {
+			return rerr
+		}
+		for _, write := range writes {
+			write.ReturnConsumedCapacity = level
+		}
+		*unprocessedItems = append(*unprocessedItems, writes...)
+	}
+	return err
+}
+
+// transactGetKey 优先使用 Key，其次根据 Meta 或表结构生成主键
+func (db *DB) transactGetKey(get *odm.TransactGet, tableName string) (map[string]*dynamodb.AttributeValue, error) {
+	if get.Key != nil {
+		return dynamodbattribute.MarshalMap(get.Key)
+	}
+	if get.Meta != nil && get.Meta.PK != nil {
+		return (&Table{TableMeta: *get.Meta, db: db}).key(get.HashKey, get.RangeKey)
+	}
+	return db.key(tableName, get.HashKey, get.RangeKey)
 }
 
 func (db *DB) TransactGetItems(gets []*odm.TransactGet, results ...odm.Model) error {
-	panic("not implemented") // TODO: Implement
+	if len(results) < len(gets) {
+		return fmt.Errorf("TransactGetItems requires a result for each get, %d gets but %d results", len(gets), len(results))
+	}
+	input := &dynamodb.TransactGetItemsInput{}
+	levels := make([]string, len(gets))
+	for i, get := range gets {
+		levels[i] = get.ReturnConsumedCapacity
+		tableName := get.TableName
+		if tableName == "" && get.Meta != nil {
+			tableName = get.Meta.TableName
+		}
+		err := validateExpressions(&expression.Expressions{
+			Projection: get.Select,
+			Names:      get.NameParams,
+		})
+		if err != nil {
+			return err
+		}
+		keyMap, err := db.transactGetKey(get, tableName)
+		if err != nil {
+			return err
+		}
+		item := &dynamodb.Get{
+			TableName: aws.String(tableName),
+			Key:       keyMap,
+		}
+		if get.Select != "" {
+			item.ProjectionExpression = aws.String(get.Select)
+		}
+		if get.NameParams != nil {
+			item.ExpressionAttributeNames = make(map[string]*string)
+			convertAttributeNames(get.NameParams, item.ExpressionAttributeNames)
+		}
+		input.TransactItems = append(input.TransactItems, &dynamodb.TransactGetItem{Get: item})
+	}
+	input.ReturnConsumedCapacity = db.returnConsumedCapacity(levels...)
+	out, err := db.GetConn().TransactGetItemsWithContext(db.Context(), input)
+	if err != nil {
+		return convertError(err)
+	}
+	db.reportConsumedCapacity("TransactGetItems", out.ConsumedCapacity...)
+	for i, get := range gets {
+		var item map[string]*dynamodb.AttributeValue
+		if i < len(out.Responses) && out.Responses[i] != nil {
+			item = out.Responses[i].Item
+		}
+		if get.Found != nil {
+			*get.Found = item != nil
+		}
+		if item == nil || results[i] == nil {
+			continue
+		}
+		if err := codec.Unmarshal(item, results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOptionParams 转换 WriteOption 中的条件表达式和参数
+func writeOptionParams(opt *odm.WriteOption) (*string, map[string]*string, map[string]*dynamodb.AttributeValue, error) {
+	if opt == nil {
+		return nil, nil, nil, nil
+	}
+	var cond *string
+	var names map[string]*string
+	var values map[string]*dynamodb.AttributeValue
+	if opt.Condition != "" {
+		cond = aws.String(opt.Condition)
+	}
+	if opt.NameParams != nil {
+		names = make(map[string]*string)
+		convertAttributeNames(opt.NameParams, names)
+	}
+	if opt.ValueParams != nil {
+		var err error
+		values, err = dynamodbattribute.MarshalMap(opt.ValueParams)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return cond, names, values, nil
+}
+
+func returnValuesOnConditionCheckFailure(returnValues string) *string {
+	if returnValues == "" {
+		return nil
+	}
+	return aws.String(returnValues)
 }
 
 func (db *DB) convertUpdate(update *odm.Update) (*dynamodb.Update, error) {
+	exprs := writeExpressions(update.WriteOption)
+	exprs.Update = update.Expression
+	if err := validateExpressions(exprs); err != nil {
+		return nil, err
+	}
 	keyMap, err := db.key(update.TableName, update.HashKey, update.RangeKey)
 	if err != nil {
 		return nil, err
 	}
 	_opts := &dynamodb.Update{
-		TableName:        aws.String(update.TableName),
-		Key:              keyMap,
-		UpdateExpression: aws.String(update.Expression),
+		TableName:                           aws.String(update.TableName),
+		Key:                                 keyMap,
+		UpdateExpression:                    aws.String(update.Expression),
+		ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(update.ReturnValuesOnConditionCheckFailure),
 	}
-	if update.WriteOption != nil {
-		if update.WriteOption.Condition != "" {
-			_opts.ConditionExpression = aws.String(update.WriteOption.Condition)
-		}
-		if update.WriteOption.NameParams != nil {
-			_opts.ExpressionAttributeNames = make(map[string]*string)
-			convertAttributeNames(update.WriteOption.NameParams, _opts.ExpressionAttributeNames)
-		}
-		if update.WriteOption.ValueParams != nil {
-			_opts.ExpressionAttributeValues, err = dynamodbattribute.MarshalMap(update.WriteOption.ValueParams)
-			if err != nil {
-				return nil, err
-			}
-		}
+	_opts.ConditionExpression, _opts.ExpressionAttributeNames, _opts.ExpressionAttributeValues, err = writeOptionParams(update.WriteOption)
+	if err != nil {
+		return nil, err
 	}
 	return _opts, nil
 }
 
 func (db *DB) convertPut(put *odm.Put) (*dynamodb.Put, error) {
-	panic("not implemented")
-	return &dynamodb.Put{
-		TableName: aws.String(put.TableName),
-	}, nil
+	if err := validateExpressions(writeExpressions(put.WriteOption)); err != nil {
+		return nil, err
+	}
+	av, err := codec.Marshal(put.Item)
+	if err != nil {
+		return nil, err
+	}
+	_opts := &dynamodb.Put{
+		TableName:                           aws.String(put.TableName),
+		Item:                                av,
+		ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(put.ReturnValuesOnConditionCheckFailure),
+	}
+	_opts.ConditionExpression, _opts.ExpressionAttributeNames, _opts.ExpressionAttributeValues, err = writeOptionParams(put.WriteOption)
+	if err != nil {
+		return nil, err
+	}
+	return _opts, nil
 }
 
 func (db *DB) convertDelete(deleted *odm.Delete) (*dynamodb.Delete, error) {
-	panic("not implemented")
-	return &dynamodb.Delete{}, nil
+	if err := validateExpressions(writeExpressions(deleted.WriteOption)); err != nil {
+		return nil, err
+	}
+	keyMap, err := db.key(deleted.TableName, deleted.HashKey, deleted.RangeKey)
+	if err != nil {
+		return nil, err
+	}
+	_opts := &dynamodb.Delete{
+		TableName:                           aws.String(deleted.TableName),
+		Key:                                 keyMap,
+		ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(deleted.ReturnValuesOnConditionCheckFailure),
+	}
+	_opts.ConditionExpression, _opts.ExpressionAttributeNames, _opts.ExpressionAttributeValues, err = writeOptionParams(deleted.WriteOption)
+	if err != nil {
+		return nil, err
+	}
+	return _opts, nil
 }
 
 func (db *DB) convertConditionCheck(check *odm.ConditionCheck) (*dynamodb.ConditionCheck, error) {
-	panic("not implemented")
-	return &dynamodb.ConditionCheck{}, nil
+	if check.Condition == "" {
+		return nil, errors.New("ConditionCheck requires a Condition expression")
+	}
+	opt := &odm.WriteOption{
+		Condition:   check.Condition,
+		NameParams:  check.NameParams,
+		ValueParams: check.ValueParams,
+	}
+	if err := validateExpressions(writeExpressions(opt)); err != nil {
+		return nil, err
+	}
+	var keyMap map[string]*dynamodb.AttributeValue
+	var err error
+	if check.Key != nil {
+		keyMap, err = dynamodbattribute.MarshalMap(check.Key)
+	} else {
+		keyMap, err = db.key(check.TableName, check.HashKey, check.RangeKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_opts := &dynamodb.ConditionCheck{
+		TableName:                           aws.String(check.TableName),
+		Key:                                 keyMap,
+		ReturnValuesOnConditionCheckFailure: returnValuesOnConditionCheckFailure(check.ReturnValuesOnConditionCheckFailure),
+	}
+	_opts.ConditionExpression, _opts.ExpressionAttributeNames, _opts.ExpressionAttributeValues, err = writeOptionParams(opt)
+	if err != nil {
+		return nil, err
+	}
+	return _opts, nil
 }
 
-func (db *DB) TransactWriteItems(writes []*odm.TransactWrite) error {
+func (db *DB) TransactWriteItems(writes []*odm.TransactWrite, opt *odm.TransactWriteOption) error {
 	items := []*dynamodb.TransactWriteItem{}
 	for _, write := range writes {
 		item := &dynamodb.TransactWriteItem{}
@@ -407,11 +873,11 @@ func (db *DB) TransactWriteItems(writes []*odm.TransactWrite) error {
 			item.ConditionCheck = conditionCheck
 		}
 		if write.Delete != nil {
-			delete, err := db.convertDelete(write.Delete)
+			del, err := db.convertDelete(write.Delete)
 			if err != nil {
 				return err
 			}
-			item.Delete = delete
+			item.Delete = del
 		}
 		if write.Update != nil {
 			update, err := db.convertUpdate(write.Update)
@@ -432,7 +898,17 @@ func (db *DB) TransactWriteItems(writes []*odm.TransactWrite) error {
 	input := &dynamodb.TransactWriteItemsInput{
 		TransactItems: items,
 	}
-	// input.ClientRequestToken = aws.String("")
-	_, err := db.GetConn().TransactWriteItems(input)
-	return err
+	level := ""
+	if opt != nil {
+		if opt.ClientRequestToken != "" {
+			input.ClientRequestToken = aws.String(opt.ClientRequestToken)
+		}
+		level = opt.ReturnConsumedCapacity
+	}
+	input.ReturnConsumedCapacity = db.returnConsumedCapacity(level)
+	out, err := db.GetConn().TransactWriteItemsWithContext(db.Context(), input)
+	if err == nil {
+		db.reportConsumedCapacity("TransactWriteItems", out.ConsumedCapacity...)
+	}
+	return convertError(err)
 }
@@ -0,0 +1,55 @@
+package dynamo
+
+import (
+	"errors"
+	"strings"
+
+	"git.devops.com/go/odm"
+	"git.devops.com/go/odm/codec"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DynamoDB 错误码对应的错误类型，TransactionCanceledException 转换为 odm.TransactionCanceledError
+var errorKinds = map[string]error{
+	dynamodb.ErrCodeConditionalCheckFailedException:          odm.ErrConditionFailed,
+	dynamodb.ErrCodeProvisionedThroughputExceededException:   odm.ErrThrottled,
+	dynamodb.ErrCodeRequestLimitExceeded:                     odm.ErrThrottled,
+	"ThrottlingException":                                    odm.ErrThrottled,
+	dynamodb.ErrCodeResourceNotFoundException:                odm.ErrTableNotFound,
+	dynamodb.ErrCodeTableNotFoundException:                   odm.ErrTableNotFound,
+	"ValidationException":                                    odm.ErrValidation,
+	dynamodb.ErrCodeIdempotentParameterMismatchException:     odm.ErrValidation,
+	dynamodb.ErrCodeTransactionConflictException:             odm.ErrTransactionConflict,
+	dynamodb.ErrCodeTransactionInProgressException:           odm.ErrTransactionConflict,
+	dynamodb.ErrCodeItemCollectionSizeLimitExceededException: odm.ErrItemTooLarge,
+	dynamodb.ErrCodeInternalServerError:                      odm.ErrUnavailable,
+	"ServiceUnavailable":                                     odm.ErrUnavailable,
+	request.ErrCodeRequestError:                              odm.ErrUnavailable,
+}
+
+// convertError 将 awserr.Error 包装为 odm.Error，使 errors.Is(err, odm.ErrConditionFailed) 等可用
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		return codec.TransactionCanceledError(canceled)
+	}
+	var oerr *odm.Error
+	if errors.As(err, &oerr) {
+		return err
+	}
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return err
+	}
+	kind := errorKinds[aerr.Code()]
+	// 超过 400KB 的 item 也返回 ValidationException，如 Item size has exceeded the maximum allowed size
+	if kind == odm.ErrValidation && strings.Contains(aerr.Message(), "size has exceeded") {
+		kind = odm.ErrItemTooLarge
+	}
+	return odm.NewError(kind, aerr.Code(), aerr.Message(), err)
+}
@@ -0,0 +1,50 @@
+package dynamo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertError(t *testing.T) {
+	assert.Nil(t, convertError(nil))
+	assert.Equal(t, assert.AnError, convertError(assert.AnError))
+
+	cause := awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	err := convertError(cause)
+	assert.True(t, errors.Is(err, odm.ErrConditionFailed))
+	assert.Equal(t, cause.Error(), err.Error())
+	// 仍然可以断言为 awserr.Error
+	aerr, ok := err.(awserr.Error)
+	assert.True(t, ok)
+	assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, aerr.Code())
+	assert.Equal(t, err, convertError(err))
+
+	err = convertError(fmt.Errorf("Fail to execute Query. %w", awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "", nil)))
+	assert.True(t, errors.Is(err, odm.ErrThrottled))
+	assert.True(t, errors.Is(convertError(awserr.New("ThrottlingException", "", nil)), odm.ErrThrottled))
+	assert.True(t, errors.Is(convertError(awserr.New(dynamodb.ErrCodeResourceNotFoundException, "", nil)), odm.ErrTableNotFound))
+	assert.True(t, errors.Is(convertError(awserr.New("ValidationException", "Invalid KeyConditionExpression", nil)), odm.ErrValidation))
+	assert.True(t, errors.Is(convertError(awserr.New("ValidationException", "Item size has exceeded the maximum allowed size", nil)), odm.ErrItemTooLarge))
+	assert.True(t, errors.Is(convertError(awserr.New(dynamodb.ErrCodeTransactionConflictException, "", nil)), odm.ErrTransactionConflict))
+	assert.True(t, errors.Is(convertError(awserr.New(dynamodb.ErrCodeInternalServerError, "", nil)), odm.ErrUnavailable))
+	assert.False(t, errors.Is(convertError(awserr.New(dynamodb.ErrCodeInternalServerError, "", nil)), odm.ErrThrottled))
+	// LimitExceededException 是控制面的配额限制，不能重试
+	assert.False(t, errors.Is(convertError(awserr.New(dynamodb.ErrCodeLimitExceededException, "", nil)), odm.ErrThrottled))
+
+	err = convertError(&dynamodb.TransactionCanceledException{
+		CancellationReasons: []*dynamodb.CancellationReason{
+			{Code: aws.String("None")},
+			{Code: aws.String("ConditionalCheckFailed")},
+		},
+	})
+	var canceled *odm.TransactionCanceledError
+	assert.True(t, errors.As(err, &canceled))
+	assert.True(t, errors.Is(err, odm.ErrConditionFailed))
+}
@@ -1,12 +1,17 @@
 package dynamo
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"git.devops.com/go/odm"
+	"git.devops.com/go/odm/codec"
+	"git.devops.com/go/odm/expression"
 	"git.devops.com/go/odm/util"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
@@ -22,6 +27,14 @@ func (t *Table) GetDB() odm.DialectDB {
 	return t.db
 }
 
+// WithContext returns a table whose requests use ctx
+func (t *Table) WithContext(ctx context.Context) odm.Table {
+	return &Table{
+		TableMeta: t.TableMeta,
+		db:        t.db.withContext(ctx),
+	}
+}
+
 // GetConn the Connection
 func (t *Table) GetConn() (*dynamodb.DynamoDB, error) {
 	if t.getPK() == "" {
@@ -40,6 +53,21 @@ func (t *Table) GetConn() (*dynamodb.DynamoDB, error) {
 	return t.db.GetConn(), nil
 }
 
+// checkIndex 检查数据库中的表是否有 indexName 对应的索引
+func (t *Table) checkIndex(indexName string) error {
+	if indexName == "" {
+		return nil
+	}
+	meta, err := t.db.GetTableMeta(t.TableName)
+	if err != nil {
+		return err
+	}
+	if meta.GetIndex(indexName) == nil {
+		return convertError(awserr.New("ValidationException", "The table does not have the specified index: "+indexName, nil))
+	}
+	return nil
+}
+
 func (t *Table) getPK() string {
 	if t.PK == nil {
 		return ""
@@ -60,9 +88,25 @@ func convertAttributeNames(params map[string]string, targetMap map[string]*strin
 	}
 }
 
-func revertAttributeNames(params map[string]string, attrNames map[string]*string) {
-	for k, v := range attrNames {
-		params[k] = *v
+// validateExpressions 在发起请求前检查表达式，避免一次无效的网络请求
+func validateExpressions(exprs *expression.Expressions) error {
+	exprs.IsReserved = func(name string) bool {
+		return IsReservedWords(strings.ToUpper(name))
+	}
+	if err := expression.Validate(exprs); err != nil {
+		return convertError(awserr.New("ValidationException", err.Error(), err))
+	}
+	return nil
+}
+
+func writeExpressions(cond *odm.WriteOption) *expression.Expressions {
+	if cond == nil {
+		return &expression.Expressions{}
+	}
+	return &expression.Expressions{
+		Condition: cond.Condition,
+		Names:     cond.NameParams,
+		Values:    cond.ValueParams,
 	}
 }
 
@@ -90,11 +134,14 @@ func (t *Table) EqualExpression(pkValue interface{}, skValue interface{}) (strin
 
 // PutItem put a item, will replace entire item. OLD will fill in result
 func (t *Table) PutItem(item odm.Model, cond *odm.WriteOption, result odm.Model) error {
+	if err := validateExpressions(writeExpressions(cond)); err != nil {
+		return err
+	}
 	conn, err := t.GetConn()
 	if err != nil {
 		return err
 	}
-	av, err := dynamodbattribute.MarshalMap(item)
+	av, err := codec.Marshal(item)
 	if err != nil {
 		return err
 	}
@@ -103,6 +150,7 @@ func (t *Table) PutItem(item odm.Model, cond *odm.WriteOption, result odm.Model)
 		Item:      av,
 		TableName: aws.String(t.TableName),
 	}
+	input.ReturnConsumedCapacity = t.db.returnConsumedCapacity(writeCapacity(cond))
 	if cond != nil {
 		if cond.ValueParams != nil {
 			input.ExpressionAttributeValues, err = dynamodbattribute.MarshalMap(cond.ValueParams)
@@ -117,20 +165,29 @@ func (t *Table) PutItem(item odm.Model, cond *odm.WriteOption, result odm.Model)
 			input.ExpressionAttributeNames = make(map[string]*string)
 			convertAttributeNames(cond.NameParams, input.ExpressionAttributeNames)
 		}
-		if result != nil {
-			// enum: NONE, ALL_OLD, UPDATED_OLD, ALL_NEW, UPDATED_NEW (for UPDATE)
-			input.ReturnValues = aws.String("UPDATED_NEW")
-		}
 	}
-	out, err := conn.PutItem(input)
+	if result != nil {
+		// PutItem 只支持 NONE、ALL_OLD，result 填充被替换的 item
+		input.ReturnValues = aws.String("ALL_OLD")
+	}
+	out, err := conn.PutItemWithContext(t.db.Context(), input)
+	err = convertError(err)
+	if err == nil {
+		t.db.reportConsumedCapacity("PutItem", out.ConsumedCapacity)
+	}
 	if result != nil && err == nil {
-		_ = dynamodbattribute.UnmarshalMap(out.Attributes, result)
+		_ = codec.Unmarshal(out.Attributes, result)
 	}
 	return err
 }
 
 // UpdateItem attributes. item will fill base on ReturnValues.
 func (t *Table) UpdateItem(pk interface{}, sk interface{}, updateExpression string, cond *odm.WriteOption, result odm.Model) error {
+	exprs := writeExpressions(cond)
+	exprs.Update = updateExpression
+	if err := validateExpressions(exprs); err != nil {
+		return err
+	}
 	conn, err := t.GetConn()
 	if err != nil {
 		return err
@@ -144,6 +201,7 @@ func (t *Table) UpdateItem(pk interface{}, sk interface{}, updateExpression stri
 		Key:              keyMap,
 		UpdateExpression: aws.String(updateExpression),
 	}
+	input.ReturnConsumedCapacity = t.db.returnConsumedCapacity(writeCapacity(cond))
 	if cond != nil {
 		if cond.ValueParams != nil {
 			input.ExpressionAttributeValues, err = dynamodbattribute.MarshalMap(cond.ValueParams)
@@ -163,15 +221,38 @@ func (t *Table) UpdateItem(pk interface{}, sk interface{}, updateExpression stri
 			input.ReturnValues = aws.String("UPDATED_NEW")
 		}
 	}
-	out, err := conn.UpdateItem(input)
+	out, err := conn.UpdateItemWithContext(t.db.Context(), input)
+	err = convertError(err)
+	if err == nil {
+		t.db.reportConsumedCapacity("UpdateItem", out.ConsumedCapacity)
+	}
 	if result != nil && err == nil {
-		_ = dynamodbattribute.UnmarshalMap(out.Attributes, result)
+		_ = codec.Unmarshal(out.Attributes, result)
 	}
 	return err
 }
 
+// UpdateFields update attributes by map, nil value will be removed
+func (t *Table) UpdateFields(pk interface{}, sk interface{}, fields odm.Map, cond *odm.WriteOption, result odm.Model) error {
+	return odm.UpdateFields(t, pk, sk, fields, cond, result)
+}
+
+// UpdateModel update non-empty or listed fields of model
+func (t *Table) UpdateModel(model odm.Model, fields []string, cond *odm.WriteOption, result odm.Model) error {
+	return codec.UpdateModel(t, model, fields, cond, result)
+}
+
 // GetItem get an item
 func (t *Table) GetItem(pk interface{}, sk interface{}, opt *odm.GetOption, item odm.Model) error {
+	if opt != nil {
+		err := validateExpressions(&expression.Expressions{
+			Projection: opt.Select,
+			Names:      opt.NameParams,
+		})
+		if err != nil {
+			return err
+		}
+	}
 	conn, err := t.GetConn()
 	if err != nil {
 		return err
@@ -184,6 +265,7 @@ func (t *Table) GetItem(pk interface{}, sk interface{}, opt *odm.GetOption, item
 		Key:       keyMap,
 		TableName: aws.String(t.TableName),
 	}
+	input.ReturnConsumedCapacity = t.db.returnConsumedCapacity(getCapacity(opt))
 	if opt != nil {
 		if opt.Consistent {
 			input.ConsistentRead = aws.Bool(opt.Consistent)
@@ -192,21 +274,29 @@ func (t *Table) GetItem(pk interface{}, sk interface{}, opt *odm.GetOption, item
 			input.ProjectionExpression = aws.String(opt.Select)
 		}
 		if opt.NameParams != nil {
+			input.ExpressionAttributeNames = make(map[string]*string)
 			convertAttributeNames(opt.NameParams, input.ExpressionAttributeNames)
 		}
 	}
-	result, err := conn.GetItem(input)
+	result, err := conn.GetItemWithContext(t.db.Context(), input)
 	if err != nil {
-		return err
+		return convertError(err)
 	}
-	if item != nil && result != nil && result.Item != nil {
-		err = dynamodbattribute.UnmarshalMap(result.Item, item)
+	t.db.reportConsumedCapacity("GetItem", result.ConsumedCapacity)
+	if result.Item == nil {
+		return odm.ErrNotFound
+	}
+	if item != nil {
+		err = codec.Unmarshal(result.Item, item)
 	}
 	return err
 }
 
 // DeleteItem returns deleted item if item provide
 func (t *Table) DeleteItem(pk interface{}, sk interface{}, cond *odm.WriteOption, result odm.Model) error {
+	if err := validateExpressions(writeExpressions(cond)); err != nil {
+		return err
+	}
 	conn, err := t.GetConn()
 	if err != nil {
 		return err
@@ -219,6 +309,7 @@ func (t *Table) DeleteItem(pk interface{}, sk interface{}, cond *odm.WriteOption
 		TableName: aws.String(t.TableName),
 		Key:       keyMap,
 	}
+	input.ReturnConsumedCapacity = t.db.returnConsumedCapacity(writeCapacity(cond))
 	if cond != nil {
 		if cond.ValueParams != nil {
 			input.ExpressionAttributeValues, err = dynamodbattribute.MarshalMap(cond.ValueParams)
@@ -233,19 +324,105 @@ func (t *Table) DeleteItem(pk interface{}, sk interface{}, cond *odm.WriteOption
 			input.ExpressionAttributeNames = make(map[string]*string)
 			convertAttributeNames(cond.NameParams, input.ExpressionAttributeNames)
 		}
-		if result != nil {
-			input.ReturnValues = aws.String("ALL_OLD")
-		}
 	}
-	out, err := conn.DeleteItem(input)
-	if result != nil && err == nil {
-		_ = dynamodbattribute.UnmarshalMap(out.Attributes, result)
+	if result != nil {
+		input.ReturnValues = aws.String("ALL_OLD")
 	}
-	return err
+	out, err := conn.DeleteItemWithContext(t.db.Context(), input)
+	if err != nil {
+		return convertError(err)
+	}
+	t.db.reportConsumedCapacity("DeleteItem", out.ConsumedCapacity)
+	if result != nil {
+		if out.Attributes == nil {
+			return odm.ErrNotFound
+		}
+		_ = codec.Unmarshal(out.Attributes, result)
+	}
+	return nil
 }
 
-func (t *Table) Scan(query *odm.QueryOption, offsetKey odm.Map, items interface{}) error {
-	panic("Not implement scan")
+// Scan the table and fill in items, offsetKey will be replaced after scan
+func (t *Table) Scan(opt *odm.ScanOption, offsetKey odm.Map, items interface{}) error {
+	if opt == nil {
+		opt = &odm.ScanOption{}
+	}
+	err := validateExpressions(&expression.Expressions{
+		Filter:     opt.Filter,
+		Projection: opt.Select,
+		Names:      opt.NameParams,
+		Values:     opt.ValueParams,
+	})
+	if err != nil {
+		return err
+	}
+	conn, err := t.GetConn()
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(t.TableName),
+		ReturnConsumedCapacity: t.db.returnConsumedCapacity(opt.ReturnConsumedCapacity),
+	}
+	if offsetKey != nil && len(offsetKey) > 0 {
+		input.ExclusiveStartKey, err = codec.MarshalKey(offsetKey)
+		if err != nil {
+			return err
+		}
+	}
+	if opt.ValueParams != nil {
+		input.ExpressionAttributeValues, err = dynamodbattribute.MarshalMap(opt.ValueParams)
+		if err != nil {
+			return err
+		}
+	}
+	if opt.NameParams != nil {
+		input.ExpressionAttributeNames = make(map[string]*string)
+		convertAttributeNames(opt.NameParams, input.ExpressionAttributeNames)
+	}
+	if opt.Filter != "" {
+		input.FilterExpression = aws.String(opt.Filter)
+	}
+	count, isCount := items.(*odm.CountResult)
+	if isCount {
+		input.Select = aws.String(dynamodb.SelectCount)
+	} else if opt.Select != "" {
+		input.ProjectionExpression = aws.String(opt.Select)
+	}
+	if opt.Consistent {
+		input.ConsistentRead = aws.Bool(opt.Consistent)
+	}
+	if opt.Limit != 0 {
+		input.Limit = aws.Int64(opt.Limit)
+	}
+	if opt.IndexName != "" {
+		if err := t.checkIndex(opt.IndexName); err != nil {
+			return err
+		}
+		input.IndexName = aws.String(opt.IndexName)
+	}
+	if opt.TotalSegments > 0 {
+		input.Segment = aws.Int64(opt.Segment)
+		input.TotalSegments = aws.Int64(opt.TotalSegments)
+	}
+	out, err := conn.ScanWithContext(t.db.Context(), input)
+	if err != nil {
+		return fmt.Errorf("Fail to execute Scan on %s. %w", t.TableName, convertError(err))
+	}
+	t.db.reportConsumedCapacity("Scan", out.ConsumedCapacity)
+	if isCount {
+		count.Count = aws.Int64Value(out.Count)
+		count.ScannedCount = aws.Int64Value(out.ScannedCount)
+	} else {
+		err = codec.UnmarshalList(out.Items, items)
+	}
+	if offsetKey != nil && err == nil {
+		for k := range offsetKey {
+			delete(offsetKey, k)
+		}
+		err = codec.UnmarshalKey(out.LastEvaluatedKey, offsetKey)
+	}
+	return err
 }
 
 // Query and fill in items, StartKey will be replaced after query
@@ -254,7 +431,17 @@ func (t *Table) Query(query *odm.QueryOption, offsetKey odm.Map, items interface
 		return errors.New("QueryOptions is required for Table.Query, ")
 	}
 	if query.KeyFilter == "" {
-		return t.Scan(query, offsetKey, items)
+		return t.Scan(&odm.ScanOption{QueryOption: *query}, offsetKey, items)
+	}
+	err := validateExpressions(&expression.Expressions{
+		KeyCondition: query.KeyFilter,
+		Filter:       query.Filter,
+		Projection:   query.Select,
+		Names:        query.NameParams,
+		Values:       query.ValueParams,
+	})
+	if err != nil {
+		return err
 	}
 	conn, err := t.GetConn()
 	if err != nil {
@@ -263,9 +450,10 @@ func (t *Table) Query(query *odm.QueryOption, offsetKey odm.Map, items interface
 	input := &dynamodb.QueryInput{
 		TableName:              aws.String(t.TableName),
 		KeyConditionExpression: aws.String(query.KeyFilter),
+		ReturnConsumedCapacity: t.db.returnConsumedCapacity(query.ReturnConsumedCapacity),
 	}
 	if offsetKey != nil && len(offsetKey) > 0 {
-		input.ExclusiveStartKey, err = dynamodbattribute.MarshalMap(offsetKey)
+		input.ExclusiveStartKey, err = codec.MarshalKey(offsetKey)
 		if err != nil {
 			return err
 		}
@@ -283,7 +471,10 @@ func (t *Table) Query(query *odm.QueryOption, offsetKey odm.Map, items interface
 	if query.Filter != "" {
 		input.FilterExpression = aws.String(query.Filter)
 	}
-	if query.Select != "" {
+	count, isCount := items.(*odm.CountResult)
+	if isCount {
+		input.Select = aws.String(dynamodb.SelectCount)
+	} else if query.Select != "" {
 		input.ProjectionExpression = aws.String(query.Select)
 	}
 	if query.Consistent {
@@ -296,19 +487,51 @@ func (t *Table) Query(query *odm.QueryOption, offsetKey odm.Map, items interface
 		input.ScanIndexForward = aws.Bool(false)
 	}
 	if query.IndexName != "" {
+		if err := t.checkIndex(query.IndexName); err != nil {
+			return err
+		}
 		input.IndexName = aws.String(query.IndexName)
 	}
-	out, err := conn.Query(input)
+	out, err := conn.QueryWithContext(t.db.Context(), input)
 	if err != nil {
-		return fmt.Errorf("Fail to execute Query on %s. %w", t.TableName, err)
+		return fmt.Errorf("Fail to execute Query on %s. %w", t.TableName, convertError(err))
+	}
+	if out != nil {
+		t.db.reportConsumedCapacity("Query", out.ConsumedCapacity)
 	}
 	if out == nil {
-		util.ClearSlice(items)
+		if !isCount {
+			util.ClearSlice(items)
+		}
+		return nil
+	}
+	if isCount {
+		count.Count = aws.Int64Value(out.Count)
+		count.ScannedCount = aws.Int64Value(out.ScannedCount)
 	} else {
-		err = dynamodbattribute.UnmarshalListOfMaps(out.Items, items)
-		if offsetKey != nil && err == nil {
-			err = dynamodbattribute.UnmarshalMap(out.LastEvaluatedKey, &offsetKey)
+		err = codec.UnmarshalList(out.Items, items)
+	}
+	if offsetKey != nil && err == nil {
+		// 最后一页没有 LastEvaluatedKey，需要先清空 offsetKey
+		for k := range offsetKey {
+			delete(offsetKey, k)
 		}
+		err = codec.UnmarshalKey(out.LastEvaluatedKey, offsetKey)
 	}
 	return err
 }
+
+// QueryIter returns an iterator which follows LastEvaluatedKey until Limit items are read
+func (t *Table) QueryIter(query *odm.QueryOption) *odm.Iterator {
+	return odm.NewQueryIter(t, query)
+}
+
+// ScanIter returns an iterator which follows LastEvaluatedKey until Limit items are read
+func (t *Table) ScanIter(opt *odm.ScanOption) *odm.Iterator {
+	return odm.NewScanIter(t, opt)
+}
+
+// Count counts items matching query with Select COUNT, following LastEvaluatedKey
+func (t *Table) Count(query *odm.QueryOption) (*odm.CountResult, error) {
+	return odm.CountItems(t, query)
+}
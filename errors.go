@@ -0,0 +1,168 @@
+package odm
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// CancellationReason 事务中单个操作的取消原因
+type CancellationReason struct {
+	// 如 None、ConditionalCheckFailed、TransactionConflict、ThrottlingError、ValidationError
+	// None 表示该操作本身没有问题
+	Code    string
+	Message string
+	// Item 条件检查失败时的旧数据，仅当 ReturnValuesOnConditionCheckFailure 为 ALL_OLD 时有值
+	Item Map
+
+	unmarshal func(result Model) error
+}
+
+// NewCancellationReason 由方言创建，unmarshal 负责将旧数据填充到 Model
+func NewCancellationReason(code string, message string, item Map, unmarshal func(result Model) error) *CancellationReason {
+	return &CancellationReason{
+		Code:      code,
+		Message:   message,
+		Item:      item,
+		unmarshal: unmarshal,
+	}
+}
+
+// Unmarshal 将旧数据填充到 result，没有旧数据时不做任何修改
+func (r *CancellationReason) Unmarshal(result Model) error {
+	if r.Item == nil || r.unmarshal == nil || result == nil {
+		return nil
+	}
+	return r.unmarshal(result)
+}
+
+// TransactionCanceledError 事务被取消，Reasons 与事务中的操作一一对应
+type TransactionCanceledError struct {
+	Reasons []*CancellationReason
+	cause   error
+}
+
+// NewTransactionCanceledError 由方言创建，cause 为底层数据库返回的原始错误
+func NewTransactionCanceledError(reasons []*CancellationReason, cause error) *TransactionCanceledError {
+	return &TransactionCanceledError{
+		Reasons: reasons,
+		cause:   cause,
+	}
+}
+
+func (e *TransactionCanceledError) Error() string {
+	codes := make([]string, len(e.Reasons))
+	for i, reason := range e.Reasons {
+		codes[i] = reason.Code
+	}
+	msg := "Transaction cancelled [" + strings.Join(codes, ", ") + "]"
+	for _, i := range e.Failed() {
+		if e.Reasons[i].Message != "" {
+			msg += "; operation " + strconv.Itoa(i) + ": " + e.Reasons[i].Message
+		}
+	}
+	return msg
+}
+
+func (e *TransactionCanceledError) Unwrap() error {
+	return e.cause
+}
+
+// Failed 返回导致事务取消的操作下标
+func (e *TransactionCanceledError) Failed() []int {
+	failed := []int{}
+	for i, reason := range e.Reasons {
+		if reason.Code != "" && reason.Code != "None" {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// ErrUnprocessed 批量操作在截止时间前仍有未处理的数据
+var ErrUnprocessed = errors.New("Batch operation has unprocessed items")
+
+// 与方言无关的错误类型，方言返回的 *Error 和 *TransactionCanceledError 可以用 errors.Is 判断
+var (
+	ErrNotFound            = errors.New("Item not found")
+	ErrConditionFailed     = errors.New("The conditional request failed")
+	ErrThrottled           = errors.New("Request throttled")
+	ErrTableNotFound       = errors.New("Table not found")
+	ErrValidation          = errors.New("Validation failed")
+	ErrTransactionConflict = errors.New("Transaction conflict")
+	ErrItemTooLarge        = errors.New("Item too large")
+	// ErrUnavailable 服务端内部错误、网络错误等暂时不可用的情况
+	ErrUnavailable = errors.New("Service unavailable")
+)
+
+// 事务取消原因的 Code 对应的错误类型
+var reasonKinds = map[string]error{
+	"ConditionalCheckFailed":          ErrConditionFailed,
+	"TransactionConflict":             ErrTransactionConflict,
+	"ThrottlingError":                 ErrThrottled,
+	"ProvisionedThroughputExceeded":   ErrThrottled,
+	"ValidationError":                 ErrValidation,
+	"ItemCollectionSizeLimitExceeded": ErrItemTooLarge,
+}
+
+// Error 方言返回的错误，Kind 为 ErrNotFound 等错误类型，cause 为底层数据库返回的原始错误。
+// 实现了 awserr.Error 接口，Code 和 Message 为底层数据库的错误码和信息
+type Error struct {
+	Kind    error
+	code    string
+	message string
+	cause   error
+}
+
+// NewError 由方言创建，kind 为 nil 时不属于任何错误类型
+func NewError(kind error, code string, message string, cause error) *Error {
+	return &Error{
+		Kind:    kind,
+		code:    code,
+		message: message,
+		cause:   cause,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.cause.Error()
+	}
+	if e.code == "" {
+		return e.message
+	}
+	return e.code + ": " + e.message
+}
+
+// Code 底层数据库的错误码，如 ConditionalCheckFailedException
+func (e *Error) Code() string {
+	return e.code
+}
+
+// Message 底层数据库的错误信息
+func (e *Error) Message() string {
+	return e.message
+}
+
+// OrigErr 底层数据库返回的原始错误
+func (e *Error) OrigErr() error {
+	return e.cause
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// Is 任一操作的取消原因属于 target 时为 true，如 errors.Is(err, ErrConditionFailed)
+func (e *TransactionCanceledError) Is(target error) bool {
+	for _, i := range e.Failed() {
+		if kind := reasonKinds[e.Reasons[i].Code]; kind != nil && kind == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package odm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionCanceledError(t *testing.T) {
+	cause := errors.New("cause")
+	err := NewTransactionCanceledError([]*CancellationReason{
+		NewCancellationReason("None", "", nil, nil),
+		NewCancellationReason("ConditionalCheckFailed", "The conditional request failed", Map{"id": 1}, func(result Model) error {
+			*result.(*int) = 1
+			return nil
+		}),
+	}, cause)
+	assert.Equal(t, []int{1}, err.Failed())
+	assert.Equal(t, "Transaction cancelled [None, ConditionalCheckFailed]; operation 1: The conditional request failed", err.Error())
+	assert.True(t, errors.Is(err, cause))
+
+	n := 0
+	assert.NoError(t, err.Reasons[0].Unmarshal(&n))
+	assert.Equal(t, 0, n)
+	assert.NoError(t, err.Reasons[1].Unmarshal(&n))
+	assert.Equal(t, 1, n)
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("cause")
+	err := NewError(ErrConditionFailed, "ConditionalCheckFailedException", "The conditional request failed", cause)
+	assert.True(t, errors.Is(err, ErrConditionFailed))
+	assert.False(t, errors.Is(err, ErrValidation))
+	assert.True(t, errors.Is(err, cause))
+	assert.Equal(t, "cause", err.Error())
+	assert.Equal(t, "ConditionalCheckFailedException", err.Code())
+
+	err = NewError(nil, "ResourceInUseException", "Table already exists", nil)
+	assert.False(t, errors.Is(err, ErrTableNotFound))
+	assert.Equal(t, "ResourceInUseException: Table already exists", err.Error())
+
+	canceled := NewTransactionCanceledError([]*CancellationReason{
+		NewCancellationReason("None", "", nil, nil),
+		NewCancellationReason("TransactionConflict", "", nil, nil),
+	}, cause)
+	assert.True(t, errors.Is(canceled, ErrTransactionConflict))
+	assert.False(t, errors.Is(canceled, ErrConditionFailed))
+	assert.True(t, errors.Is(canceled, cause))
+}
@@ -0,0 +1,132 @@
+package expr
+
+import "git.devops.com/go/odm"
+
+// Builder 组合多个表达式，所有表达式共享同一组占位符
+type Builder struct {
+	condition    ConditionBuilder
+	filter       ConditionBuilder
+	keyCondition ConditionBuilder
+	update       *UpdateBuilder
+	projection   []NameBuilder
+}
+
+// NewBuilder 创建 Builder
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// WithCondition 设置 ConditionExpression
+func (b *Builder) WithCondition(c ConditionBuilder) *Builder {
+	b.condition = c
+	return b
+}
+
+// WithFilter 设置 FilterExpression
+func (b *Builder) WithFilter(c ConditionBuilder) *Builder {
+	b.filter = c
+	return b
+}
+
+// WithKeyCondition 设置 KeyConditionExpression
+func (b *Builder) WithKeyCondition(c ConditionBuilder) *Builder {
+	b.keyCondition = c
+	return b
+}
+
+// WithUpdate 设置 UpdateExpression
+func (b *Builder) WithUpdate(u *UpdateBuilder) *Builder {
+	b.update = u
+	return b
+}
+
+// WithProjection 设置 ProjectionExpression
+func (b *Builder) WithProjection(names ...NameBuilder) *Builder {
+	b.projection = names
+	return b
+}
+
+// Expression 渲染后的表达式和占位符
+type Expression struct {
+	Condition    string
+	Filter       string
+	KeyCondition string
+	Update       string
+	Projection   string
+	// Names 没有需要替换的属性名时为 nil
+	Names map[string]string
+	// Values 没有值时为 nil
+	Values odm.Map
+}
+
+// Build 渲染所有表达式，未设置的表达式为空字符串
+func (b *Builder) Build() (*Expression, error) {
+	r := newRenderer()
+	e := &Expression{}
+	var err error
+	for _, c := range []struct {
+		cond ConditionBuilder
+		expr *string
+	}{
+		{b.keyCondition, &e.KeyCondition},
+		{b.condition, &e.Condition},
+		{b.filter, &e.Filter},
+	} {
+		if !c.cond.IsSet() {
+			continue
+		}
+		if *c.expr, err = c.cond.render(r); err != nil {
+			return nil, err
+		}
+	}
+	if b.update != nil {
+		if e.Update, err = b.update.render(r); err != nil {
+			return nil, err
+		}
+	}
+	for i, name := range b.projection {
+		s, err := name.render(r)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			e.Projection += ", "
+		}
+		e.Projection += s
+	}
+	if len(r.nameParams) > 0 {
+		e.Names = r.nameParams
+	}
+	if len(r.values) > 0 {
+		e.Values = r.values
+	}
+	return e, nil
+}
+
+// WriteOption 转换为 PutItem、UpdateItem、DeleteItem 和事务使用的条件
+func (e *Expression) WriteOption() *odm.WriteOption {
+	return &odm.WriteOption{
+		Condition:   e.Condition,
+		NameParams:  e.Names,
+		ValueParams: e.Values,
+	}
+}
+
+// QueryOption 转换为 Query、Scan 使用的选项
+func (e *Expression) QueryOption() *odm.QueryOption {
+	return &odm.QueryOption{
+		KeyFilter:   e.KeyCondition,
+		Filter:      e.Filter,
+		Select:      e.Projection,
+		NameParams:  e.Names,
+		ValueParams: e.Values,
+	}
+}
+
+// GetOption 转换为 GetItem 使用的选项
+func (e *Expression) GetOption() *odm.GetOption {
+	return &odm.GetOption{
+		Select:     e.Projection,
+		NameParams: e.Names,
+	}
+}
@@ -0,0 +1,160 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+)
+
+// ConditionBuilder 条件表达式，用于 ConditionExpression、FilterExpression 和 KeyConditionExpression。
+// 零值表示没有条件，And、Or 会忽略零值，方便动态拼接条件
+type ConditionBuilder struct {
+	node *node
+}
+
+// IsSet 判断是否设置了条件
+func (c ConditionBuilder) IsSet() bool {
+	return c.node != nil
+}
+
+func (c ConditionBuilder) render(r *renderer) (string, error) {
+	if c.node == nil {
+		return "", errors.New("Condition is empty")
+	}
+	return c.node.render(r)
+}
+
+func condition(format string, args ...Operand) ConditionBuilder {
+	return ConditionBuilder{node: &node{format: format, args: args}}
+}
+
+func compare(op string, left Operand, right interface{}) ConditionBuilder {
+	return condition("%s "+op+" %s", left, operand(right))
+}
+
+// Eq 返回 path = v，v 可以是值或其他 Operand
+func (n NameBuilder) Eq(v interface{}) ConditionBuilder { return compare("=", n, v) }
+
+// Ne 返回 path <> v
+func (n NameBuilder) Ne(v interface{}) ConditionBuilder { return compare("<>", n, v) }
+
+// Lt 返回 path < v
+func (n NameBuilder) Lt(v interface{}) ConditionBuilder { return compare("<", n, v) }
+
+// Le 返回 path <= v
+func (n NameBuilder) Le(v interface{}) ConditionBuilder { return compare("<=", n, v) }
+
+// Gt 返回 path > v
+func (n NameBuilder) Gt(v interface{}) ConditionBuilder { return compare(">", n, v) }
+
+// Ge 返回 path >= v
+func (n NameBuilder) Ge(v interface{}) ConditionBuilder { return compare(">=", n, v) }
+
+// Eq 返回 size(path) = v
+func (s SizeBuilder) Eq(v interface{}) ConditionBuilder { return compare("=", s, v) }
+
+// Ne 返回 size(path) <> v
+func (s SizeBuilder) Ne(v interface{}) ConditionBuilder { return compare("<>", s, v) }
+
+// Lt 返回 size(path) < v
+func (s SizeBuilder) Lt(v interface{}) ConditionBuilder { return compare("<", s, v) }
+
+// Le 返回 size(path) <= v
+func (s SizeBuilder) Le(v interface{}) ConditionBuilder { return compare("<=", s, v) }
+
+// Gt 返回 size(path) > v
+func (s SizeBuilder) Gt(v interface{}) ConditionBuilder { return compare(">", s, v) }
+
+// Ge 返回 size(path) >= v
+func (s SizeBuilder) Ge(v interface{}) ConditionBuilder { return compare(">=", s, v) }
+
+// Between 返回 path BETWEEN low AND high
+func (n NameBuilder) Between(low interface{}, high interface{}) ConditionBuilder {
+	return condition("%s BETWEEN %s AND %s", n, operand(low), operand(high))
+}
+
+// In 返回 path IN (v1, v2, ...)
+func (n NameBuilder) In(values ...interface{}) ConditionBuilder {
+	if len(values) == 0 {
+		return ConditionBuilder{node: &node{err: errors.New("IN requires at least one value")}}
+	}
+	args := []Operand{n}
+	for _, v := range values {
+		args = append(args, operand(v))
+	}
+	return condition("%s IN ("+strings.Repeat(", %s", len(values))[2:]+")", args...)
+}
+
+// BeginsWith 返回 begins_with(path, prefix)
+func (n NameBuilder) BeginsWith(prefix string) ConditionBuilder {
+	return condition("begins_with(%s, %s)", n, Value(prefix))
+}
+
+// Contains 返回 contains(path, v)
+func (n NameBuilder) Contains(v interface{}) ConditionBuilder {
+	return condition("contains(%s, %s)", n, operand(v))
+}
+
+// AttributeExists 返回 attribute_exists(path)
+func (n NameBuilder) AttributeExists() ConditionBuilder {
+	return condition("attribute_exists(%s)", n)
+}
+
+// AttributeNotExists 返回 attribute_not_exists(path)
+func (n NameBuilder) AttributeNotExists() ConditionBuilder {
+	return condition("attribute_not_exists(%s)", n)
+}
+
+// AttributeType 返回 attribute_type(path, t)，t 如 S、N、L、M
+func (n NameBuilder) AttributeType(t string) ConditionBuilder {
+	return condition("attribute_type(%s, %s)", n, Value(t))
+}
+
+// join 用 op 连接所有已设置的条件，只有一个条件时不加括号
+func join(op string, conds []ConditionBuilder) ConditionBuilder {
+	args := []Operand{}
+	for _, c := range conds {
+		if c.IsSet() {
+			args = append(args, c)
+		}
+	}
+	switch len(args) {
+	case 0:
+		return ConditionBuilder{}
+	case 1:
+		return args[0].(ConditionBuilder)
+	}
+	return condition("("+strings.Repeat(") "+op+" (%s", len(args))[len(op)+4:]+")", args...)
+}
+
+// And 返回所有条件同时成立
+func And(conds ...ConditionBuilder) ConditionBuilder {
+	return join("AND", conds)
+}
+
+// Or 返回任一条件成立
+func Or(conds ...ConditionBuilder) ConditionBuilder {
+	return join("OR", conds)
+}
+
+// Not 返回条件不成立
+func Not(c ConditionBuilder) ConditionBuilder {
+	if !c.IsSet() {
+		return c
+	}
+	return condition("NOT (%s)", c)
+}
+
+// And 返回 c 与 others 同时成立
+func (c ConditionBuilder) And(others ...ConditionBuilder) ConditionBuilder {
+	return And(append([]ConditionBuilder{c}, others...)...)
+}
+
+// Or 返回 c 与 others 任一成立
+func (c ConditionBuilder) Or(others ...ConditionBuilder) ConditionBuilder {
+	return Or(append([]ConditionBuilder{c}, others...)...)
+}
+
+// Not 返回 c 不成立
+func (c ConditionBuilder) Not() ConditionBuilder {
+	return Not(c)
+}
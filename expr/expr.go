@@ -0,0 +1,198 @@
+// Package expr 用于构造 DynamoDB 的条件、过滤、主键条件、更新和投影表达式。
+//
+// 属性名和值会自动替换为 #n0、:v0 形式的占位符：属性名只有在是保留字或包含特殊字符时才会被替换，
+// 值总是被替换。构造结果可以直接转换为 odm.WriteOption、odm.QueryOption 和 odm.GetOption。
+// Example:
+//
+//	e, err := expr.NewBuilder().
+//		WithCondition(expr.Name("Status").Eq(1).And(expr.Name("Count").Gt(0))).
+//		WithUpdate(expr.Name("Count").Add(-1).Set("Status", 2)).
+//		Build()
+//	table.UpdateItem(id, nil, e.Update, e.WriteOption(), nil)
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"git.devops.com/go/odm"
+	"git.devops.com/go/odm/expression"
+)
+
+// Operand 表达式中的操作数，可以是属性名、值、函数或算术运算
+type Operand interface {
+	render(r *renderer) (string, error)
+}
+
+// node 按 format 将子节点渲染后拼接，是所有操作数、条件和动作的实现
+type node struct {
+	format string
+	args   []Operand
+	err    error
+}
+
+func (n *node) render(r *renderer) (string, error) {
+	if n.err != nil {
+		return "", n.err
+	}
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		s, err := arg.render(r)
+		if err != nil {
+			return "", err
+		}
+		args[i] = s
+	}
+	return fmt.Sprintf(n.format, args...), nil
+}
+
+// operand 将普通的 Go 值包装为 Value，Operand 原样返回
+func operand(v interface{}) Operand {
+	if o, ok := v.(Operand); ok {
+		return o
+	}
+	return Value(v)
+}
+
+// NameBuilder 属性路径，如 Age、Info.Tags[0]
+type NameBuilder struct {
+	path string
+}
+
+// Name 创建属性路径，以 . 分隔嵌套文档，以 [n] 访问列表元素
+func Name(path string) NameBuilder {
+	return NameBuilder{path: path}
+}
+
+func (n NameBuilder) render(r *renderer) (string, error) {
+	return r.name(n.path)
+}
+
+// ValueBuilder 值，渲染为 :v0 形式的占位符
+type ValueBuilder struct {
+	value interface{}
+}
+
+// Value 创建值
+func Value(v interface{}) ValueBuilder {
+	return ValueBuilder{value: v}
+}
+
+func (v ValueBuilder) render(r *renderer) (string, error) {
+	return r.value(v.value), nil
+}
+
+// Size 返回 size(path)，可以用于比较
+func (n NameBuilder) Size() SizeBuilder {
+	return SizeBuilder{name: n}
+}
+
+// SizeBuilder 属性的长度 size(path)
+type SizeBuilder struct {
+	name NameBuilder
+}
+
+func (s SizeBuilder) render(r *renderer) (string, error) {
+	name, err := s.name.render(r)
+	return "size(" + name + ")", err
+}
+
+// Plus 返回 path + v，用于 SET
+func (n NameBuilder) Plus(v interface{}) Operand {
+	return &node{format: "%s + %s", args: []Operand{n, operand(v)}}
+}
+
+// Minus 返回 path - v，用于 SET
+func (n NameBuilder) Minus(v interface{}) Operand {
+	return &node{format: "%s - %s", args: []Operand{n, operand(v)}}
+}
+
+// IfNotExists 返回 if_not_exists(path, v)，用于 SET
+func (n NameBuilder) IfNotExists(v interface{}) Operand {
+	return &node{format: "if_not_exists(%s, %s)", args: []Operand{n, operand(v)}}
+}
+
+// ListAppend 返回 list_append(path, v)，用于 SET
+func (n NameBuilder) ListAppend(v interface{}) Operand {
+	return &node{format: "list_append(%s, %s)", args: []Operand{n, operand(v)}}
+}
+
+// renderer 渲染时分配占位符，同一个 Builder 中的所有表达式共享占位符
+type renderer struct {
+	names      map[string]string
+	nameParams map[string]string
+	values     odm.Map
+}
+
+func newRenderer() *renderer {
+	return &renderer{
+		names:      map[string]string{},
+		nameParams: map[string]string{},
+		values:     odm.Map{},
+	}
+}
+
+// name 渲染属性路径，保留字和包含特殊字符的属性名替换为 #n0 形式的占位符
+func (r *renderer) name(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("Attribute path is empty")
+	}
+	elems := strings.Split(path, ".")
+	for i, elem := range elems {
+		attr, index := elem, ""
+		if j := strings.IndexByte(elem, '['); j >= 0 {
+			attr, index = elem[:j], elem[j:]
+			if !validIndex(index) {
+				return "", fmt.Errorf("Invalid list index in attribute path: %s", path)
+			}
+		}
+		if attr == "" {
+			return "", fmt.Errorf("Invalid attribute path: %s", path)
+		}
+		if !isIdent(attr) || expression.IsReservedWords(strings.ToUpper(attr)) {
+			placeholder := r.names[attr]
+			if placeholder == "" {
+				placeholder = "#n" + strconv.Itoa(len(r.names))
+				r.names[attr] = placeholder
+				r.nameParams[placeholder] = attr
+			}
+			attr = placeholder
+		}
+		elems[i] = attr + index
+	}
+	return strings.Join(elems, "."), nil
+}
+
+func (r *renderer) value(v interface{}) string {
+	placeholder := ":v" + strconv.Itoa(len(r.values))
+	r.values[placeholder] = v
+	return placeholder
+}
+
+func isIdent(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// validIndex 检查形如 [0][1] 的列表下标
+func validIndex(s string) bool {
+	for s != "" {
+		end := strings.IndexByte(s, ']')
+		if s[0] != '[' || end < 2 {
+			return false
+		}
+		if _, err := strconv.ParseUint(s[1:end], 10, 32); err != nil {
+			return false
+		}
+		s = s[end+1:]
+	}
+	return true
+}
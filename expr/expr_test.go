@@ -0,0 +1,121 @@
+package expr
+
+import (
+	"testing"
+
+	"git.devops.com/go/odm"
+	_ "git.devops.com/go/odm/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestName(t *testing.T) {
+	r := newRenderer()
+	for _, c := range [][2]string{
+		{"Age", "Age"},
+		{"Info.Tags[0]", "Info.Tags[0]"},
+		{"Status", "#n0"},
+		{"Info.Status[1]", "Info.#n0[1]"},
+		{"first-name", "#n1"},
+		{"Name.Size", "#n2.#n3"},
+	} {
+		s, err := r.name(c[0])
+		assert.NoError(t, err, c[0])
+		assert.Equal(t, c[1], s, c[0])
+	}
+	assert.Equal(t, map[string]string{"#n0": "Status", "#n1": "first-name", "#n2": "Name", "#n3": "Size"}, r.nameParams)
+
+	for _, path := range []string{"", "a..b", "a[x]", "a[0", "[0]"} {
+		_, err := r.name(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestCondition(t *testing.T) {
+	e, err := NewBuilder().WithCondition(
+		Name("Status").Eq(1).And(
+			Name("Age").Between(10, 20),
+			Or(Name("Tags").Contains("go"), Not(Name("Deleted").AttributeExists())),
+			ConditionBuilder{},
+		),
+	).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "(#n0 = :v0) AND (Age BETWEEN :v1 AND :v2) AND ((contains(Tags, :v3)) OR (NOT (attribute_exists(Deleted))))", e.Condition)
+	assert.Equal(t, map[string]string{"#n0": "Status"}, e.Names)
+	assert.Equal(t, odm.Map{":v0": 1, ":v1": 10, ":v2": 20, ":v3": "go"}, e.Values)
+
+	e, err = NewBuilder().
+		WithKeyCondition(Name("Author").Eq("Tom").And(Name("Title").BeginsWith("Hello"))).
+		WithFilter(Name("Age").In(1, 2, 3).And(Name("Info").Size().Gt(0))).
+		WithProjection(Name("Author"), Name("Comment")).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "(Author = :v0) AND (begins_with(Title, :v1))", e.KeyCondition)
+	assert.Equal(t, "(Age IN (:v2, :v3, :v4)) AND (size(Info) > :v5)", e.Filter)
+	assert.Equal(t, "Author, #n0", e.Projection)
+	assert.Equal(t, map[string]string{"#n0": "Comment"}, e.Names)
+
+	e, err = NewBuilder().Build()
+	assert.NoError(t, err)
+	assert.Equal(t, &Expression{}, e)
+
+	_, err = NewBuilder().WithFilter(Name("Age").In()).Build()
+	assert.Error(t, err)
+}
+
+func TestUpdate(t *testing.T) {
+	e, err := NewBuilder().WithUpdate(
+		Name("Count").Add(-1).
+			Set("Age", Name("Age").Plus(1)).
+			Set("Status", 2).
+			Remove("Info.Nick").
+			Delete("Tags", []string{"a"}),
+	).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET Age = Age + :v0, #n0 = :v1 REMOVE Info.Nick ADD #n1 :v2 DELETE Tags :v3", e.Update)
+	assert.Equal(t, map[string]string{"#n0": "Status", "#n1": "Count"}, e.Names)
+
+	e, err = NewBuilder().WithUpdate(Append("Logs", "a", "b")).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "SET Logs = list_append(if_not_exists(Logs, :v0), :v1)", e.Update)
+	assert.Equal(t, odm.Map{":v0": emptyList{}, ":v1": []interface{}{"a", "b"}}, e.Values)
+
+	_, err = NewBuilder().WithUpdate(&UpdateBuilder{}).Build()
+	assert.Error(t, err)
+}
+
+type Book struct {
+	Author  string `odm:"PK"`
+	Title   string `odm:"SK"`
+	Age     int64
+	Status  int
+	Comment string
+	Logs    []string `json:",omitempty"`
+}
+
+func TestExpression_Memory(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	table := db.Table(&Book{})
+	assert.NoError(t, table.PutItem(&Book{Author: "Tom", Title: "Hello", Age: 10, Status: 1}, nil, nil))
+
+	e, err := NewBuilder().
+		WithCondition(Name("Status").Eq(1)).
+		WithUpdate(Name("Age").Add(1).Set("Status", 2).Set("Comment", "good").Append("Logs", "updated")).
+		Build()
+	assert.NoError(t, err)
+	book := &Book{}
+	opt := e.WriteOption()
+	assert.NoError(t, table.UpdateItem("Tom", "Hello", e.Update, opt, book))
+	assert.Equal(t, &Book{Age: 11, Status: 2, Comment: "good", Logs: []string{"updated"}}, book)
+	assert.Error(t, table.UpdateItem("Tom", "Hello", e.Update, opt, nil))
+
+	e, err = NewBuilder().
+		WithKeyCondition(Name("Author").Eq("Tom")).
+		WithFilter(Name("Comment").BeginsWith("go")).
+		WithProjection(Name("Title"), Name("Comment")).
+		Build()
+	assert.NoError(t, err)
+	books := []Book{}
+	assert.NoError(t, table.Query(e.QueryOption(), nil, &books))
+	assert.Equal(t, []Book{{Title: "Hello", Comment: "good"}}, books)
+}
@@ -0,0 +1,126 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// UpdateBuilder 更新表达式，按 SET、REMOVE、ADD、DELETE 分组渲染
+type UpdateBuilder struct {
+	sets    []Operand
+	removes []Operand
+	adds    []Operand
+	deletes []Operand
+}
+
+// Set 返回 SET path = v，v 可以是值或 Plus、IfNotExists 等 Operand
+func Set(path string, v interface{}) *UpdateBuilder {
+	return (&UpdateBuilder{}).Set(path, v)
+}
+
+// Remove 返回 REMOVE path1, path2
+func Remove(paths ...string) *UpdateBuilder {
+	return (&UpdateBuilder{}).Remove(paths...)
+}
+
+// Append 返回将 values 追加到列表末尾的 SET，列表不存在时先创建空列表
+func Append(path string, values ...interface{}) *UpdateBuilder {
+	return (&UpdateBuilder{}).Append(path, values...)
+}
+
+// Add 返回 ADD path v，对数字累加，对集合添加元素
+func (n NameBuilder) Add(v interface{}) *UpdateBuilder {
+	return (&UpdateBuilder{}).Add(n.path, v)
+}
+
+// Delete 返回 DELETE path v，从集合中删除元素
+func (n NameBuilder) Delete(v interface{}) *UpdateBuilder {
+	return (&UpdateBuilder{}).Delete(n.path, v)
+}
+
+// Set 返回 SET path = v
+func (n NameBuilder) Set(v interface{}) *UpdateBuilder {
+	return Set(n.path, v)
+}
+
+// Remove 返回 REMOVE path
+func (n NameBuilder) Remove() *UpdateBuilder {
+	return Remove(n.path)
+}
+
+// Set 追加 SET path = v
+func (u *UpdateBuilder) Set(path string, v interface{}) *UpdateBuilder {
+	u.sets = append(u.sets, &node{format: "%s = %s", args: []Operand{Name(path), operand(v)}})
+	return u
+}
+
+// Remove 追加 REMOVE path
+func (u *UpdateBuilder) Remove(paths ...string) *UpdateBuilder {
+	for _, path := range paths {
+		u.removes = append(u.removes, Name(path))
+	}
+	return u
+}
+
+// Append 追加 SET path = list_append(if_not_exists(path, []), values)
+func (u *UpdateBuilder) Append(path string, values ...interface{}) *UpdateBuilder {
+	if values == nil {
+		values = []interface{}{}
+	}
+	return u.Set(path, &node{
+		format: "list_append(if_not_exists(%s, %s), %s)",
+		args:   []Operand{Name(path), Value(emptyList{}), Value(values)},
+	})
+}
+
+// emptyList 空列表。dynamodbattribute 会把空 slice 编码为 NULL，不能用于 list_append
+type emptyList struct{}
+
+func (emptyList) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	av.L = []*dynamodb.AttributeValue{}
+	return nil
+}
+
+// Add 追加 ADD path v
+func (u *UpdateBuilder) Add(path string, v interface{}) *UpdateBuilder {
+	u.adds = append(u.adds, &node{format: "%s %s", args: []Operand{Name(path), operand(v)}})
+	return u
+}
+
+// Delete 追加 DELETE path v
+func (u *UpdateBuilder) Delete(path string, v interface{}) *UpdateBuilder {
+	u.deletes = append(u.deletes, &node{format: "%s %s", args: []Operand{Name(path), operand(v)}})
+	return u
+}
+
+func (u *UpdateBuilder) render(r *renderer) (string, error) {
+	clauses := []string{}
+	for _, group := range []struct {
+		keyword string
+		actions []Operand
+	}{
+		{"SET", u.sets},
+		{"REMOVE", u.removes},
+		{"ADD", u.adds},
+		{"DELETE", u.deletes},
+	} {
+		if len(group.actions) == 0 {
+			continue
+		}
+		actions := make([]string, len(group.actions))
+		for i, action := range group.actions {
+			s, err := action.render(r)
+			if err != nil {
+				return "", err
+			}
+			actions[i] = s
+		}
+		clauses = append(clauses, group.keyword+" "+strings.Join(actions, ", "))
+	}
+	if len(clauses) == 0 {
+		return "", errors.New("Update expression is empty")
+	}
+	return strings.Join(clauses, " "), nil
+}
@@ -0,0 +1,250 @@
+package expression
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Operator 比较运算符和算术运算符
+type Operator string
+
+const (
+	EQ    Operator = "="
+	NE    Operator = "<>"
+	LT    Operator = "<"
+	LE    Operator = "<="
+	GT    Operator = ">"
+	GE    Operator = ">="
+	Plus  Operator = "+"
+	Minus Operator = "-"
+)
+
+// PathElem 文档路径中的一段，可以是属性名（或#占位符），也可以是列表下标
+type PathElem struct {
+	Name    string
+	Index   int
+	IsIndex bool
+}
+
+// Path 文档路径，如 a.b[1].#c
+type Path []PathElem
+
+func (p Path) String() string {
+	var sb strings.Builder
+	for i, e := range p {
+		if e.IsIndex {
+			sb.WriteString("[" + strconv.Itoa(e.Index) + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(".")
+		}
+		sb.WriteString(e.Name)
+	}
+	return sb.String()
+}
+
+// Operand 操作数
+type Operand interface {
+	isOperand()
+}
+
+// PathOperand 引用 item 中的属性
+type PathOperand struct {
+	Path Path
+}
+
+// ValueOperand 引用 ExpressionAttributeValues 中的值，Name 形如 :value
+type ValueOperand struct {
+	Name string
+}
+
+// FunctionOperand 返回值的函数：size、if_not_exists、list_append
+type FunctionOperand struct {
+	Name string
+	Args []Operand
+}
+
+// ArithOperand 仅用于 SET 中的 a + b, a - b
+type ArithOperand struct {
+	Op    Operator
+	Left  Operand
+	Right Operand
+}
+
+func (*PathOperand) isOperand()     {}
+func (*ValueOperand) isOperand()    {}
+func (*FunctionOperand) isOperand() {}
+func (*ArithOperand) isOperand()    {}
+
+// Condition 条件表达式节点
+type Condition interface {
+	isCondition()
+}
+
+type AndCondition struct {
+	Left, Right Condition
+}
+
+type OrCondition struct {
+	Left, Right Condition
+}
+
+type NotCondition struct {
+	Condition Condition
+}
+
+type CompareCondition struct {
+	Op          Operator
+	Left, Right Operand
+}
+
+type BetweenCondition struct {
+	Value, Lower, Upper Operand
+}
+
+type InCondition struct {
+	Value Operand
+	List  []Operand
+}
+
+// FunctionCondition 返回布尔值的函数：attribute_exists、attribute_not_exists、
+// attribute_type、begins_with、contains
+type FunctionCondition struct {
+	Name string
+	Args []Operand
+}
+
+func (*AndCondition) isCondition()      {}
+func (*OrCondition) isCondition()       {}
+func (*NotCondition) isCondition()      {}
+func (*CompareCondition) isCondition()  {}
+func (*BetweenCondition) isCondition()  {}
+func (*InCondition) isCondition()       {}
+func (*FunctionCondition) isCondition() {}
+
+// SetAction SET path = value
+type SetAction struct {
+	Path  Path
+	Value Operand
+}
+
+// AddAction ADD path :value 或 DELETE path :value
+type AddAction struct {
+	Path  Path
+	Value Operand
+}
+
+// Update 解析后的 UpdateExpression
+type Update struct {
+	Set    []*SetAction
+	Remove []Path
+	Add    []*AddAction
+	Delete []*AddAction
+}
+
+// Paths 返回 UpdateExpression 修改的全部路径
+func (u *Update) Paths() []Path {
+	paths := append([]Path{}, u.Remove...)
+	for _, action := range u.Set {
+		paths = append(paths, action.Path)
+	}
+	for _, action := range u.Add {
+		paths = append(paths, action.Path)
+	}
+	for _, action := range u.Delete {
+		paths = append(paths, action.Path)
+	}
+	return paths
+}
+
+// placeholders 收集表达式中用到的 #name 和 :value 占位符
+type placeholders struct {
+	names  map[string]bool
+	values map[string]bool
+	// 未使用占位符的属性名，用于检查保留字
+	attributes []string
+}
+
+func newPlaceholders() *placeholders {
+	return &placeholders{
+		names:  map[string]bool{},
+		values: map[string]bool{},
+	}
+}
+
+func (ph *placeholders) path(p Path) {
+	for _, e := range p {
+		if e.IsIndex {
+			continue
+		}
+		if strings.HasPrefix(e.Name, "#") {
+			ph.names[e.Name] = true
+		} else {
+			ph.attributes = append(ph.attributes, e.Name)
+		}
+	}
+}
+
+func (ph *placeholders) operand(op Operand) {
+	switch op := op.(type) {
+	case *PathOperand:
+		ph.path(op.Path)
+	case *ValueOperand:
+		ph.values[op.Name] = true
+	case *FunctionOperand:
+		for _, arg := range op.Args {
+			ph.operand(arg)
+		}
+	case *ArithOperand:
+		ph.operand(op.Left)
+		ph.operand(op.Right)
+	}
+}
+
+func (ph *placeholders) condition(cond Condition) {
+	switch c := cond.(type) {
+	case *AndCondition:
+		ph.condition(c.Left)
+		ph.condition(c.Right)
+	case *OrCondition:
+		ph.condition(c.Left)
+		ph.condition(c.Right)
+	case *NotCondition:
+		ph.condition(c.Condition)
+	case *CompareCondition:
+		ph.operand(c.Left)
+		ph.operand(c.Right)
+	case *BetweenCondition:
+		ph.operand(c.Value)
+		ph.operand(c.Lower)
+		ph.operand(c.Upper)
+	case *InCondition:
+		ph.operand(c.Value)
+		for _, op := range c.List {
+			ph.operand(op)
+		}
+	case *FunctionCondition:
+		for _, arg := range c.Args {
+			ph.operand(arg)
+		}
+	}
+}
+
+func (ph *placeholders) update(u *Update) {
+	for _, action := range u.Set {
+		ph.path(action.Path)
+		ph.operand(action.Value)
+	}
+	for _, p := range u.Remove {
+		ph.path(p)
+	}
+	for _, action := range u.Add {
+		ph.path(action.Path)
+		ph.operand(action.Value)
+	}
+	for _, action := range u.Delete {
+		ph.path(action.Path)
+		ph.operand(action.Value)
+	}
+}
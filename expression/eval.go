@@ -0,0 +1,852 @@
+package expression
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Item 以 DynamoDB 数据模型表示的一条记录
+type Item = map[string]*dynamodb.AttributeValue
+
+// Env 表达式求值环境，对应 ExpressionAttributeNames 和 ExpressionAttributeValues
+type Env struct {
+	Names  map[string]string
+	Values Item
+}
+
+// Name 将 #name 占位符替换为属性名
+func (e *Env) Name(n string) (string, error) {
+	if !strings.HasPrefix(n, "#") {
+		return n, nil
+	}
+	v, ok := e.Names[n]
+	if !ok {
+		return "", fmt.Errorf("An expression attribute name used in the document path is not defined; attribute name: %s", n)
+	}
+	return v, nil
+}
+
+// Value 返回 :value 占位符对应的值
+func (e *Env) Value(n string) (*dynamodb.AttributeValue, error) {
+	v, ok := e.Values[n]
+	if !ok || v == nil {
+		return nil, fmt.Errorf("An expression attribute value used in expression is not defined; attribute value: %s", n)
+	}
+	return v, nil
+}
+
+// Resolve 将路径中的 #name 占位符替换为真实属性名
+func (e *Env) Resolve(p Path) (Path, error) {
+	resolved := make(Path, len(p))
+	for i, elem := range p {
+		if !elem.IsIndex {
+			name, err := e.Name(elem.Name)
+			if err != nil {
+				return nil, err
+			}
+			elem.Name = name
+		}
+		resolved[i] = elem
+	}
+	return resolved, nil
+}
+
+// Lookup 根据文档路径取值，不存在时返回 nil。p 必须是已经 Resolve 的路径
+func Lookup(it Item, p Path) *dynamodb.AttributeValue {
+	if len(p) == 0 || p[0].IsIndex {
+		return nil
+	}
+	v := it[p[0].Name]
+	for _, elem := range p[1:] {
+		if v == nil {
+			return nil
+		}
+		if elem.IsIndex {
+			if v.L == nil || elem.Index >= len(v.L) {
+				return nil
+			}
+			v = v.L[elem.Index]
+		} else {
+			if v.M == nil {
+				return nil
+			}
+			v = v.M[elem.Name]
+		}
+	}
+	return v
+}
+
+// Operand 对操作数求值，属性不存在时返回 nil
+func (e *Env) Operand(it Item, op Operand) (*dynamodb.AttributeValue, error) {
+	switch op := op.(type) {
+	case *PathOperand:
+		p, err := e.Resolve(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return Lookup(it, p), nil
+	case *ValueOperand:
+		return e.Value(op.Name)
+	case *FunctionOperand:
+		return e.function(it, op)
+	case *ArithOperand:
+		left, err := e.Operand(it, op.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.Operand(it, op.Right)
+		if err != nil {
+			return nil, err
+		}
+		if left == nil || right == nil || left.N == nil || right.N == nil {
+			return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+		}
+		if op.Op == Plus {
+			return addNumber(*left.N, *right.N)
+		}
+		return subNumber(*left.N, *right.N)
+	}
+	return nil, fmt.Errorf("Unknown operand %T", op)
+}
+
+func (e *Env) function(it Item, fn *FunctionOperand) (*dynamodb.AttributeValue, error) {
+	switch fn.Name {
+	case "size":
+		v, err := e.Operand(it, fn.Args[0])
+		if err != nil || v == nil {
+			return nil, err
+		}
+		n, ok := sizeOf(v)
+		if !ok {
+			return nil, nil
+		}
+		return &dynamodb.AttributeValue{N: &n}, nil
+	case "if_not_exists":
+		v, err := e.Operand(it, fn.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			return v, nil
+		}
+		return e.Operand(it, fn.Args[1])
+	case "list_append":
+		left, err := e.Operand(it, fn.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.Operand(it, fn.Args[1])
+		if err != nil {
+			return nil, err
+		}
+		if left == nil || right == nil || left.L == nil || right.L == nil {
+			return nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+		}
+		list := make([]*dynamodb.AttributeValue, 0, len(left.L)+len(right.L))
+		list = append(list, left.L...)
+		list = append(list, right.L...)
+		return &dynamodb.AttributeValue{L: list}, nil
+	}
+	return nil, fmt.Errorf("Invalid function name; function: %s", fn.Name)
+}
+
+func sizeOf(v *dynamodb.AttributeValue) (string, bool) {
+	n := -1
+	switch {
+	case v.S != nil:
+		n = len(*v.S)
+	case v.B != nil:
+		n = len(v.B)
+	case v.SS != nil:
+		n = len(v.SS)
+	case v.NS != nil:
+		n = len(v.NS)
+	case v.BS != nil:
+		n = len(v.BS)
+	case v.L != nil:
+		n = len(v.L)
+	case v.M != nil:
+		n = len(v.M)
+	}
+	if n < 0 {
+		return "", false
+	}
+	return fmt.Sprint(n), true
+}
+
+// EvalCondition 对 item 求条件表达式的值
+func (e *Env) EvalCondition(it Item, cond Condition) (bool, error) {
+	switch c := cond.(type) {
+	case *AndCondition:
+		left, err := e.EvalCondition(it, c.Left)
+		if err != nil {
+			return false, err
+		}
+		right, err := e.EvalCondition(it, c.Right)
+		return left && right, err
+	case *OrCondition:
+		left, err := e.EvalCondition(it, c.Left)
+		if err != nil {
+			return false, err
+		}
+		right, err := e.EvalCondition(it, c.Right)
+		return left || right, err
+	case *NotCondition:
+		v, err := e.EvalCondition(it, c.Condition)
+		return !v, err
+	case *CompareCondition:
+		left, err := e.Operand(it, c.Left)
+		if err != nil {
+			return false, err
+		}
+		right, err := e.Operand(it, c.Right)
+		if err != nil {
+			return false, err
+		}
+		return compareOp(c.Op, left, right), nil
+	case *BetweenCondition:
+		v, err := e.Operand(it, c.Value)
+		if err != nil {
+			return false, err
+		}
+		lower, err := e.Operand(it, c.Lower)
+		if err != nil {
+			return false, err
+		}
+		upper, err := e.Operand(it, c.Upper)
+		if err != nil {
+			return false, err
+		}
+		return compareOp(GE, v, lower) && compareOp(LE, v, upper), nil
+	case *InCondition:
+		v, err := e.Operand(it, c.Value)
+		if err != nil {
+			return false, err
+		}
+		for _, op := range c.List {
+			candidate, err := e.Operand(it, op)
+			if err != nil {
+				return false, err
+			}
+			if compareOp(EQ, v, candidate) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case *FunctionCondition:
+		return e.evalFunction(it, c)
+	}
+	return false, fmt.Errorf("Unknown condition %T", cond)
+}
+
+func (e *Env) evalFunction(it Item, fn *FunctionCondition) (bool, error) {
+	v, err := e.Operand(it, fn.Args[0])
+	if err != nil {
+		return false, err
+	}
+	switch fn.Name {
+	case "attribute_exists":
+		return v != nil, nil
+	case "attribute_not_exists":
+		return v == nil, nil
+	}
+	arg, err := e.Operand(it, fn.Args[1])
+	if err != nil {
+		return false, err
+	}
+	if v == nil || arg == nil {
+		return false, nil
+	}
+	switch fn.Name {
+	case "attribute_type":
+		if arg.S == nil {
+			return false, fmt.Errorf("Invalid attribute type name found in attribute_type")
+		}
+		return TypeOf(v) == *arg.S, nil
+	case "begins_with":
+		if v.S != nil && arg.S != nil {
+			return strings.HasPrefix(*v.S, *arg.S), nil
+		}
+		if v.B != nil && arg.B != nil {
+			return bytes.HasPrefix(v.B, arg.B), nil
+		}
+		return false, nil
+	case "contains":
+		switch {
+		case v.S != nil && arg.S != nil:
+			return strings.Contains(*v.S, *arg.S), nil
+		case v.B != nil && arg.B != nil:
+			return bytes.Contains(v.B, arg.B), nil
+		case v.SS != nil && arg.S != nil:
+			return indexOfSet(v, arg) >= 0, nil
+		case v.NS != nil && arg.N != nil:
+			return indexOfSet(v, arg) >= 0, nil
+		case v.BS != nil && arg.B != nil:
+			return indexOfSet(v, arg) >= 0, nil
+		case v.L != nil:
+			for _, elem := range v.L {
+				if compareOp(EQ, elem, arg) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("Invalid function name; function: %s", fn.Name)
+}
+
+// TypeOf 返回 DynamoDB 数据类型描述符
+func TypeOf(v *dynamodb.AttributeValue) string {
+	switch {
+	case v.S != nil:
+		return "S"
+	case v.N != nil:
+		return "N"
+	case v.B != nil:
+		return "B"
+	case v.BOOL != nil:
+		return "BOOL"
+	case v.NULL != nil:
+		return "NULL"
+	case v.SS != nil:
+		return "SS"
+	case v.NS != nil:
+		return "NS"
+	case v.BS != nil:
+		return "BS"
+	case v.L != nil:
+		return "L"
+	case v.M != nil:
+		return "M"
+	}
+	return ""
+}
+
+func compareOp(op Operator, left, right *dynamodb.AttributeValue) bool {
+	if left == nil || right == nil {
+		return false
+	}
+	if op == EQ {
+		return Equal(left, right)
+	}
+	if op == NE {
+		return !Equal(left, right)
+	}
+	c, ok := Compare(left, right)
+	if !ok {
+		return false
+	}
+	switch op {
+	case LT:
+		return c < 0
+	case LE:
+		return c <= 0
+	case GT:
+		return c > 0
+	case GE:
+		return c >= 0
+	}
+	return false
+}
+
+// Compare 比较两个标量（S、N、B），类型不一致时 ok 为 false
+func Compare(left, right *dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case left.S != nil && right.S != nil:
+		return strings.Compare(*left.S, *right.S), true
+	case left.N != nil && right.N != nil:
+		return compareNumber(*left.N, *right.N), true
+	case left.B != nil && right.B != nil:
+		return bytes.Compare(left.B, right.B), true
+	}
+	return 0, false
+}
+
+// Equal 按 DynamoDB 的语义比较两个值是否相等，集合不考虑顺序
+func Equal(left, right *dynamodb.AttributeValue) bool {
+	if TypeOf(left) != TypeOf(right) {
+		return false
+	}
+	switch TypeOf(left) {
+	case "S", "N", "B":
+		c, _ := Compare(left, right)
+		return c == 0
+	case "BOOL":
+		return *left.BOOL == *right.BOOL
+	case "NULL":
+		return true
+	case "SS", "NS", "BS":
+		if setLen(left) != setLen(right) {
+			return false
+		}
+		for _, elem := range setElems(left) {
+			if indexOfSet(right, elem) < 0 {
+				return false
+			}
+		}
+		return true
+	case "L":
+		if len(left.L) != len(right.L) {
+			return false
+		}
+		for i := range left.L {
+			if !Equal(left.L[i], right.L[i]) {
+				return false
+			}
+		}
+		return true
+	case "M":
+		if len(left.M) != len(right.M) {
+			return false
+		}
+		for k, v := range left.M {
+			if r, ok := right.M[k]; !ok || !Equal(v, r) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func parseNumber(n string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(n))
+	if !ok {
+		return nil, fmt.Errorf("A value provided cannot be converted into a number: %s", n)
+	}
+	return r, nil
+}
+
+func compareNumber(a, b string) int {
+	ra, err := parseNumber(a)
+	if err != nil {
+		return strings.Compare(a, b)
+	}
+	rb, err := parseNumber(b)
+	if err != nil {
+		return strings.Compare(a, b)
+	}
+	return ra.Cmp(rb)
+}
+
+// formatNumber 输出精确的十进制表示
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := r.FloatString(38)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// NormalizeNumber 将数字规范化，如 "1.50" 与 "1.5" 得到相同的结果
+func NormalizeNumber(n string) (string, error) {
+	r, err := parseNumber(n)
+	if err != nil {
+		return "", err
+	}
+	return formatNumber(r), nil
+}
+
+func addNumber(a, b string) (*dynamodb.AttributeValue, error) {
+	ra, err := parseNumber(a)
+	if err != nil {
+		return nil, err
+	}
+	rb, err := parseNumber(b)
+	if err != nil {
+		return nil, err
+	}
+	n := formatNumber(new(big.Rat).Add(ra, rb))
+	return &dynamodb.AttributeValue{N: &n}, nil
+}
+
+func subNumber(a, b string) (*dynamodb.AttributeValue, error) {
+	ra, err := parseNumber(a)
+	if err != nil {
+		return nil, err
+	}
+	rb, err := parseNumber(b)
+	if err != nil {
+		return nil, err
+	}
+	n := formatNumber(new(big.Rat).Sub(ra, rb))
+	return &dynamodb.AttributeValue{N: &n}, nil
+}
+
+func setLen(v *dynamodb.AttributeValue) int {
+	return len(v.SS) + len(v.NS) + len(v.BS)
+}
+
+// setElems 将集合拆分为单个标量
+func setElems(v *dynamodb.AttributeValue) []*dynamodb.AttributeValue {
+	elems := []*dynamodb.AttributeValue{}
+	for _, s := range v.SS {
+		elems = append(elems, &dynamodb.AttributeValue{S: s})
+	}
+	for _, n := range v.NS {
+		elems = append(elems, &dynamodb.AttributeValue{N: n})
+	}
+	for _, b := range v.BS {
+		elems = append(elems, &dynamodb.AttributeValue{B: b})
+	}
+	return elems
+}
+
+func indexOfSet(set *dynamodb.AttributeValue, elem *dynamodb.AttributeValue) int {
+	for i, e := range setElems(set) {
+		if Equal(e, elem) {
+			return i
+		}
+	}
+	return -1
+}
+
+func appendSet(set *dynamodb.AttributeValue, elem *dynamodb.AttributeValue) {
+	switch {
+	case elem.S != nil:
+		set.SS = append(set.SS, elem.S)
+	case elem.N != nil:
+		set.NS = append(set.NS, elem.N)
+	case elem.B != nil:
+		set.BS = append(set.BS, elem.B)
+	}
+}
+
+// ApplyUpdate 对 item 执行 UpdateExpression，返回新 item 和被更新的顶层属性名，原 item 不会被修改。
+// 与 DynamoDB 一致，所有操作数均基于更新前的 item 求值。
+func (e *Env) ApplyUpdate(old Item, update *Update) (Item, []string, error) {
+	it := CopyItem(old)
+	if it == nil {
+		it = Item{}
+	}
+	updated := []string{}
+	touched := map[string]bool{}
+	touch := func(p Path) {
+		if !touched[p[0].Name] {
+			touched[p[0].Name] = true
+			updated = append(updated, p[0].Name)
+		}
+	}
+	for _, action := range update.Set {
+		p, err := e.Resolve(action.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, err := e.Operand(old, action.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v == nil {
+			return nil, nil, fmt.Errorf("The provided expression refers to an attribute that does not exist in the item")
+		}
+		if err = setPath(it, p, CopyValue(v)); err != nil {
+			return nil, nil, err
+		}
+		touch(p)
+	}
+	for _, action := range update.Add {
+		p, err := e.Resolve(action.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, err := e.Operand(old, action.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		current := Lookup(it, p)
+		var result *dynamodb.AttributeValue
+		switch {
+		case v.N != nil && current == nil:
+			result = CopyValue(v)
+		case v.N != nil && current.N != nil:
+			result, err = addNumber(*current.N, *v.N)
+			if err != nil {
+				return nil, nil, err
+			}
+		case setLen(v) > 0 && current == nil:
+			result = CopyValue(v)
+		case setLen(v) > 0 && TypeOf(v) == TypeOf(current):
+			result = CopyValue(current)
+			for _, elem := range setElems(v) {
+				if indexOfSet(result, elem) < 0 {
+					appendSet(result, elem)
+				}
+			}
+		default:
+			return nil, nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+		}
+		if err = setPath(it, p, result); err != nil {
+			return nil, nil, err
+		}
+		touch(p)
+	}
+	for _, action := range update.Delete {
+		p, err := e.Resolve(action.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, err := e.Operand(old, action.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		current := Lookup(it, p)
+		if current == nil {
+			continue
+		}
+		if setLen(v) == 0 || TypeOf(v) != TypeOf(current) {
+			return nil, nil, fmt.Errorf("An operand in the update expression has an incorrect data type")
+		}
+		result := &dynamodb.AttributeValue{}
+		for _, elem := range setElems(current) {
+			if indexOfSet(v, elem) < 0 {
+				appendSet(result, elem)
+			}
+		}
+		if setLen(result) == 0 {
+			removePath(it, p)
+		} else if err = setPath(it, p, result); err != nil {
+			return nil, nil, err
+		}
+		touch(p)
+	}
+	for _, rp := range update.Remove {
+		p, err := e.Resolve(rp)
+		if err != nil {
+			return nil, nil, err
+		}
+		removePath(it, p)
+	}
+	return it, updated, nil
+}
+
+func setPath(it Item, p Path, v *dynamodb.AttributeValue) error {
+	if len(p) == 1 {
+		it[p[0].Name] = v
+		return nil
+	}
+	parent := Lookup(it, p[:len(p)-1])
+	last := p[len(p)-1]
+	invalid := fmt.Errorf("The document path provided in the update expression is invalid for update")
+	if parent == nil {
+		return invalid
+	}
+	if last.IsIndex {
+		if parent.L == nil {
+			return invalid
+		}
+		if last.Index >= len(parent.L) {
+			parent.L = append(parent.L, v)
+		} else {
+			parent.L[last.Index] = v
+		}
+		return nil
+	}
+	if parent.M == nil {
+		return invalid
+	}
+	parent.M[last.Name] = v
+	return nil
+}
+
+func removePath(it Item, p Path) {
+	if len(p) == 1 {
+		delete(it, p[0].Name)
+		return
+	}
+	parent := Lookup(it, p[:len(p)-1])
+	last := p[len(p)-1]
+	if parent == nil {
+		return
+	}
+	if last.IsIndex {
+		if parent.L != nil && last.Index < len(parent.L) {
+			parent.L = append(parent.L[:last.Index], parent.L[last.Index+1:]...)
+		}
+		return
+	}
+	if parent.M != nil {
+		delete(parent.M, last.Name)
+	}
+}
+
+// Project 根据 ProjectionExpression 裁剪 item
+func (e *Env) Project(it Item, paths []Path) (Item, error) {
+	result := Item{}
+	for _, rp := range paths {
+		p, err := e.Resolve(rp)
+		if err != nil {
+			return nil, err
+		}
+		v := Lookup(it, p)
+		if v == nil {
+			continue
+		}
+		if err := projectPath(result, p, CopyValue(v)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// projectPath 在 result 中按路径构建嵌套结构，列表下标会被压缩
+func projectPath(result Item, p Path, v *dynamodb.AttributeValue) error {
+	if len(p) == 1 {
+		result[p[0].Name] = v
+		return nil
+	}
+	container := result[p[0].Name]
+	if container == nil {
+		if p[1].IsIndex {
+			container = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+		} else {
+			container = &dynamodb.AttributeValue{M: Item{}}
+		}
+		result[p[0].Name] = container
+	}
+	for i := 1; i < len(p); i++ {
+		elem := p[i]
+		last := i == len(p)-1
+		var child *dynamodb.AttributeValue
+		if last {
+			child = v
+		} else if p[i+1].IsIndex {
+			child = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+		} else {
+			child = &dynamodb.AttributeValue{M: Item{}}
+		}
+		if elem.IsIndex {
+			if container.L == nil {
+				return fmt.Errorf("Two document paths overlap with each other")
+			}
+			container.L = append(container.L, child)
+		} else {
+			if container.M == nil {
+				return fmt.Errorf("Two document paths overlap with each other")
+			}
+			if existing, ok := container.M[elem.Name]; ok && !last {
+				child = existing
+			} else {
+				container.M[elem.Name] = child
+			}
+		}
+		container = child
+	}
+	return nil
+}
+
+// KeyCondition 从 KeyConditionExpression 中拆分出 hashKey 的值和 rangeKey 的条件
+func (e *Env) KeyCondition(cond Condition, hashKey string, rangeKey string) (*dynamodb.AttributeValue, []Condition, error) {
+	conds := []Condition{}
+	var flatten func(c Condition)
+	flatten = func(c Condition) {
+		if and, ok := c.(*AndCondition); ok {
+			flatten(and.Left)
+			flatten(and.Right)
+			return
+		}
+		conds = append(conds, c)
+	}
+	flatten(cond)
+	var hashValue *dynamodb.AttributeValue
+	rangeConds := []Condition{}
+	for _, c := range conds {
+		var attr Operand
+		switch c := c.(type) {
+		case *CompareCondition:
+			attr = c.Left
+			if c.Op == NE {
+				return nil, nil, fmt.Errorf("Unsupported operator in KeyConditionExpression: <>")
+			}
+		case *BetweenCondition:
+			attr = c.Value
+		case *FunctionCondition:
+			if c.Name != "begins_with" {
+				return nil, nil, fmt.Errorf("Invalid operator used in KeyConditionExpression: %s", c.Name)
+			}
+			attr = c.Args[0]
+		default:
+			return nil, nil, fmt.Errorf("Invalid operator used in KeyConditionExpression")
+		}
+		p, ok := attr.(*PathOperand)
+		if !ok || len(p.Path) != 1 {
+			return nil, nil, fmt.Errorf("KeyConditionExpression should reference key attribute on the left side")
+		}
+		name, err := e.Name(p.Path[0].Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case name == hashKey && hashValue == nil:
+			cmp, ok := c.(*CompareCondition)
+			if !ok || cmp.Op != EQ {
+				return nil, nil, fmt.Errorf("Query key condition not supported")
+			}
+			if hashValue, err = e.Operand(Item{}, cmp.Right); err != nil {
+				return nil, nil, err
+			}
+		case name == rangeKey && len(rangeConds) == 0:
+			rangeConds = append(rangeConds, c)
+		default:
+			return nil, nil, fmt.Errorf("Query key condition not supported")
+		}
+	}
+	if hashValue == nil {
+		return nil, nil, fmt.Errorf("Query condition missed key schema element: %s", hashKey)
+	}
+	return hashValue, rangeConds, nil
+}
+
+// CopyValue 深拷贝
+func CopyValue(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if v == nil {
+		return nil
+	}
+	c := &dynamodb.AttributeValue{
+		S:    v.S,
+		N:    v.N,
+		BOOL: v.BOOL,
+		NULL: v.NULL,
+	}
+	if v.B != nil {
+		c.B = append([]byte{}, v.B...)
+	}
+	if v.SS != nil {
+		c.SS = append([]*string{}, v.SS...)
+	}
+	if v.NS != nil {
+		c.NS = append([]*string{}, v.NS...)
+	}
+	if v.BS != nil {
+		c.BS = make([][]byte, len(v.BS))
+		for i, b := range v.BS {
+			c.BS[i] = append([]byte{}, b...)
+		}
+	}
+	if v.L != nil {
+		c.L = make([]*dynamodb.AttributeValue, len(v.L))
+		for i, elem := range v.L {
+			c.L[i] = CopyValue(elem)
+		}
+	}
+	if v.M != nil {
+		c.M = CopyItem(v.M)
+	}
+	return c
+}
+
+// CopyItem 深拷贝
+func CopyItem(it Item) Item {
+	if it == nil {
+		return nil
+	}
+	c := make(Item, len(it))
+	for k, v := range it {
+		c[k] = CopyValue(v)
+	}
+	return c
+}
@@ -0,0 +1,173 @@
+// Package expression 解析并求值 DynamoDB 表达式。
+//
+// 支持 ConditionExpression / FilterExpression、KeyConditionExpression、
+// UpdateExpression（SET/REMOVE/ADD/DELETE）和 ProjectionExpression。
+// 非 Dynamo 的方言和客户端缓存可以用它实现与 DynamoDB 一致的语义，
+// 也可以在发起请求前用 Validate 检查表达式。
+package expression
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// NewEnv 使用 odm 的 NameParams、ValueParams 创建求值环境
+func NewEnv(names map[string]string, values odm.Map) (*Env, error) {
+	e := &Env{Names: names}
+	if values != nil {
+		av, err := dynamodbattribute.MarshalMap(values)
+		if err != nil {
+			return nil, err
+		}
+		e.Values = av
+	}
+	return e, nil
+}
+
+// Match 判断 item 是否满足条件表达式，空表达式总是满足
+func Match(it odm.Map, cond string, names map[string]string, values odm.Map) (bool, error) {
+	if cond == "" {
+		return true, nil
+	}
+	c, err := ParseCondition(cond)
+	if err != nil {
+		return false, err
+	}
+	e, err := NewEnv(names, values)
+	if err != nil {
+		return false, err
+	}
+	av, err := dynamodbattribute.MarshalMap(it)
+	if err != nil {
+		return false, err
+	}
+	return e.EvalCondition(av, c)
+}
+
+// Apply 对 item 执行更新表达式，返回更新后的新 item
+func Apply(it odm.Map, update string, names map[string]string, values odm.Map) (odm.Map, error) {
+	u, err := ParseUpdate(update)
+	if err != nil {
+		return nil, err
+	}
+	e, err := NewEnv(names, values)
+	if err != nil {
+		return nil, err
+	}
+	av, err := dynamodbattribute.MarshalMap(it)
+	if err != nil {
+		return nil, err
+	}
+	av, _, err = e.ApplyUpdate(av, u)
+	if err != nil {
+		return nil, err
+	}
+	result := odm.Map{}
+	err = dynamodbattribute.UnmarshalMap(av, &result)
+	return result, err
+}
+
+// Expressions 一次请求中的全部表达式及参数
+type Expressions struct {
+	Condition    string
+	Filter       string
+	KeyCondition string
+	Update       string
+	Projection   string
+
+	Names  map[string]string
+	Values odm.Map
+
+	// IsReserved 判断属性名是否是保留字，为 nil 时不检查
+	IsReserved func(name string) bool
+}
+
+// Validate 检查表达式的语法，以及占位符是否都已定义、是否都被使用
+func Validate(exprs *Expressions) error {
+	ph := newPlaceholders()
+	conditions := []struct {
+		name string
+		expr string
+	}{
+		{"ConditionExpression", exprs.Condition},
+		{"FilterExpression", exprs.Filter},
+		{"KeyConditionExpression", exprs.KeyCondition},
+	}
+	for _, c := range conditions {
+		if c.expr == "" {
+			continue
+		}
+		cond, err := ParseCondition(c.expr)
+		if err != nil {
+			return fmt.Errorf("Invalid %s: %s", c.name, err.Error())
+		}
+		ph.condition(cond)
+	}
+	if exprs.Update != "" {
+		update, err := ParseUpdate(exprs.Update)
+		if err != nil {
+			return fmt.Errorf("Invalid UpdateExpression: %s", err.Error())
+		}
+		ph.update(update)
+	}
+	if exprs.Projection != "" {
+		paths, err := ParseProjection(exprs.Projection)
+		if err != nil {
+			return fmt.Errorf("Invalid ProjectionExpression: %s", err.Error())
+		}
+		for _, p := range paths {
+			ph.path(p)
+		}
+	}
+
+	if exprs.IsReserved != nil {
+		for _, name := range ph.attributes {
+			if exprs.IsReserved(name) {
+				return fmt.Errorf("Attribute name is a reserved keyword; reserved keyword: %s", name)
+			}
+		}
+	}
+	for _, name := range sortedKeys(ph.names) {
+		if _, ok := exprs.Names[name]; !ok {
+			return fmt.Errorf("An expression attribute name used in the document path is not defined; attribute name: %s", name)
+		}
+	}
+	for _, value := range sortedKeys(ph.values) {
+		if _, ok := exprs.Values[value]; !ok {
+			return fmt.Errorf("An expression attribute value used in expression is not defined; attribute value: %s", value)
+		}
+	}
+	unused := []string{}
+	for name := range exprs.Names {
+		if !ph.names[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return fmt.Errorf("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", strings.Join(unused, ", "))
+	}
+	for value := range exprs.Values {
+		if !ph.values[value] {
+			unused = append(unused, value)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return fmt.Errorf("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", strings.Join(unused, ", "))
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
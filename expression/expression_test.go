@@ -0,0 +1,142 @@
+package expression
+
+import (
+	"testing"
+
+	"git.devops.com/go/odm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCondition(t *testing.T) {
+	cond, err := ParseCondition("a.b[1] = :v AND NOT (size(c) > :n OR begins_with(#d, :p))")
+	assert.NoError(t, err)
+	and, ok := cond.(*AndCondition)
+	assert.True(t, ok)
+	cmp := and.Left.(*CompareCondition)
+	assert.Equal(t, EQ, cmp.Op)
+	assert.Equal(t, "a.b[1]", cmp.Left.(*PathOperand).Path.String())
+	_, ok = and.Right.(*NotCondition)
+	assert.True(t, ok)
+
+	for _, expr := range []string{
+		"",
+		"a =",
+		"a = :v AND",
+		"a BETWEEN :x",
+		"unknown_fn(a)",
+		"a IN ()",
+		"(a = :v",
+	} {
+		_, err := ParseCondition(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestParseUpdate(t *testing.T) {
+	update, err := ParseUpdate("SET a = a + :inc, b = list_append(if_not_exists(b, :empty), :l) REMOVE c[0], d ADD e :one DELETE f :s")
+	assert.NoError(t, err)
+	assert.Len(t, update.Set, 2)
+	assert.Len(t, update.Remove, 2)
+	assert.Len(t, update.Add, 1)
+	assert.Len(t, update.Delete, 1)
+	assert.Len(t, update.Paths(), 6)
+
+	for _, expr := range []string{
+		"",
+		"SET",
+		"SET a",
+		"SET a = :v SET b = :v",
+		"ADD a",
+		"UPSERT a = :v",
+	} {
+		_, err := ParseUpdate(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	item := odm.Map{
+		"Author": "Tom",
+		"Age":    10,
+		"Tags":   []string{"go", "db"},
+		"Info":   odm.Map{"Lang": "zh"},
+	}
+	cases := []struct {
+		cond   string
+		values odm.Map
+		expect bool
+	}{
+		{"Age = :v", odm.Map{":v": 10}, true},
+		{"Age <> :v", odm.Map{":v": 10}, false},
+		{"Age BETWEEN :a AND :b", odm.Map{":a": 5, ":b": 10}, true},
+		{"Author IN (:a, :b)", odm.Map{":a": "Jack", ":b": "Tom"}, true},
+		{"attribute_exists(Info.Lang)", nil, true},
+		{"attribute_not_exists(Title)", nil, true},
+		{"begins_with(Author, :p)", odm.Map{":p": "To"}, true},
+		{"contains(Tags, :t)", odm.Map{":t": "db"}, true},
+		{"size(Tags) > :n", odm.Map{":n": 2}, false},
+		{"Tags[1] = :t AND attribute_type(Age, :type)", odm.Map{":t": "db", ":type": "N"}, true},
+		{"NOT Age > :v OR #i.#l = :l", odm.Map{":v": 1, ":l": "zh"}, true},
+	}
+	for _, c := range cases {
+		ok, err := Match(item, c.cond, map[string]string{"#i": "Info", "#l": "Lang"}, c.values)
+		assert.NoError(t, err, c.cond)
+		assert.Equal(t, c.expect, ok, c.cond)
+	}
+	_, err := Match(item, "Age = :missing", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	item := odm.Map{
+		"Age":  10,
+		"Tags": []string{"a"},
+		"Info": odm.Map{"Lang": "zh"},
+		"Old":  true,
+	}
+	result, err := Apply(item, "SET Age = Age + :inc, Tags = list_append(Tags, :tags), Info.Lang = :lang, New = if_not_exists(New, :new) REMOVE Old", nil, odm.Map{
+		":inc":  5,
+		":tags": []string{"b"},
+		":lang": "en",
+		":new":  "x",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, odm.Map{
+		"Age":  float64(15),
+		"Tags": []interface{}{"a", "b"},
+		"Info": map[string]interface{}{"Lang": "en"},
+		"New":  "x",
+	}, result)
+	// 原 item 不会被修改
+	assert.Equal(t, true, item["Old"])
+
+	_, err = Apply(item, "SET Info.Missing.Lang = :lang", nil, odm.Map{":lang": "en"})
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	isReserved := func(name string) bool {
+		return name == "count"
+	}
+	err := Validate(&Expressions{
+		Condition:  "attribute_exists(#c)",
+		Update:     "SET #c = #c + :inc",
+		Projection: "Age",
+		Names:      map[string]string{"#c": "count"},
+		Values:     odm.Map{":inc": 1},
+		IsReserved: isReserved,
+	})
+	assert.NoError(t, err)
+
+	invalid := []*Expressions{
+		{Condition: "Age ="},
+		{Update: "SET count = :v", Values: odm.Map{":v": 1}, IsReserved: isReserved},
+		{Filter: "#a = :v", Values: odm.Map{":v": 1}},
+		{Filter: "Age = :v"},
+		{Filter: "Age = :v", Values: odm.Map{":v": 1, ":unused": 2}},
+		{KeyCondition: "Age = :v", Names: map[string]string{"#unused": "x"}, Values: odm.Map{":v": 1}},
+	}
+	for _, exprs := range invalid {
+		assert.Error(t, Validate(exprs), "%+v", exprs)
+	}
+}
@@ -0,0 +1,582 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 本文件实现 DynamoDB 表达式的词法、语法解析。
+// 支持 ConditionExpression、FilterExpression、KeyConditionExpression、
+// UpdateExpression 与 ProjectionExpression。
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokName  // #name
+	tokValue // :value
+	tokNumber
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokDot
+	tokEQ
+	tokNE
+	tokLT
+	tokLE
+	tokGT
+	tokGE
+	tokPlus
+	tokMinus
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(expr string) ([]token, error) {
+	tokens := []token{}
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{tokLBracket, "[", i})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{tokRBracket, "]", i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokComma, ",", i})
+			i++
+		case c == '.':
+			tokens = append(tokens, token{tokDot, ".", i})
+			i++
+		case c == '+':
+			tokens = append(tokens, token{tokPlus, "+", i})
+			i++
+		case c == '-':
+			tokens = append(tokens, token{tokMinus, "-", i})
+			i++
+		case c == '=':
+			tokens = append(tokens, token{tokEQ, "=", i})
+			i++
+		case c == '<':
+			if i+1 < len(expr) && expr[i+1] == '>' {
+				tokens = append(tokens, token{tokNE, "<>", i})
+				i += 2
+			} else if i+1 < len(expr) && expr[i+1] == '=' {
+				tokens = append(tokens, token{tokLE, "<=", i})
+				i += 2
+			} else {
+				tokens = append(tokens, token{tokLT, "<", i})
+				i++
+			}
+		case c == '>':
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				tokens = append(tokens, token{tokGE, ">=", i})
+				i += 2
+			} else {
+				tokens = append(tokens, token{tokGT, ">", i})
+				i++
+			}
+		case c == '#' || c == ':':
+			j := i + 1
+			for j < len(expr) && isIdentChar(expr[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("Syntax error; token: \"%c\", near position %d", c, i)
+			}
+			kind := tokName
+			if c == ':' {
+				kind = tokValue
+			}
+			tokens = append(tokens, token{kind, expr[i:j], i})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && expr[j] >= '0' && expr[j] <= '9' {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, expr[i:j], i})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(expr) && isIdentChar(expr[j]) {
+				j++
+			}
+			tokens = append(tokens, token{tokIdent, expr[i:j], i})
+			i = j
+		default:
+			return nil, fmt.Errorf("Invalid character \"%c\" in expression, near position %d", c, i)
+		}
+	}
+	tokens = append(tokens, token{tokEOF, "", len(expr)})
+	return tokens, nil
+}
+
+var operators = map[tokenKind]Operator{
+	tokEQ:    EQ,
+	tokNE:    NE,
+	tokLT:    LT,
+	tokLE:    LE,
+	tokGT:    GT,
+	tokGE:    GE,
+	tokPlus:  Plus,
+	tokMinus: Minus,
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	expr   string
+}
+
+func newParser(expr string) (*parser, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens, expr: expr}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	text := t.text
+	if t.kind == tokEOF {
+		text = "<EOF>"
+	}
+	return fmt.Errorf("Invalid expression %q: %s; token: %q, near position %d", p.expr, fmt.Sprintf(format, args...), text, t.pos)
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.peek()
+	if t.kind != kind {
+		return t, p.errorf("expect %s", what)
+	}
+	return p.next(), nil
+}
+
+// ParseCondition 解析 Condition/Filter/KeyCondition 表达式
+func ParseCondition(expr string) (Condition, error) {
+	p, err := newParser(expr)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokEOF {
+		return nil, fmt.Errorf("Invalid expression: The expression can not be empty")
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return cond, nil
+}
+
+func (p *parser) parseOr() (Condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &OrCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &AndCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (Condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		cond, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &NotCondition{cond}, nil
+	}
+	return p.parsePredicate()
+}
+
+var conditionFunctions = map[string]int{
+	"attribute_exists":     1,
+	"attribute_not_exists": 1,
+	"attribute_type":       2,
+	"begins_with":          2,
+	"contains":             2,
+}
+
+func (p *parser) parsePredicate() (Condition, error) {
+	t := p.peek()
+	if t.kind == tokLParen {
+		// 可能是 (condition)，也可能是 (operand) 但 DynamoDB 不支持括号包裹操作数
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "\")\""); err != nil {
+			return nil, err
+		}
+		return cond, nil
+	}
+	if t.kind == tokIdent && p.tokens[p.pos+1].kind == tokLParen {
+		if argc, ok := conditionFunctions[t.text]; ok {
+			p.next()
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != argc {
+				return nil, fmt.Errorf("Invalid expression %q: Incorrect number of operands for operator or function; operator or function: %s, number of operands: %d", p.expr, t.text, len(args))
+			}
+			if _, ok := args[0].(*PathOperand); !ok {
+				return nil, fmt.Errorf("Invalid expression %q: Operator or function requires a document path; operator or function: %s", p.expr, t.text)
+			}
+			return &FunctionCondition{Name: t.text, Args: args}, nil
+		}
+	}
+	left, err := p.parseOperand(false)
+	if err != nil {
+		return nil, err
+	}
+	t = p.peek()
+	switch {
+	case t.kind >= tokEQ && t.kind <= tokGE:
+		p.next()
+		right, err := p.parseOperand(false)
+		if err != nil {
+			return nil, err
+		}
+		return &CompareCondition{Op: operators[t.kind], Left: left, Right: right}, nil
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		lower, err := p.parseOperand(false)
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.errorf("expect AND in BETWEEN")
+		}
+		p.next()
+		upper, err := p.parseOperand(false)
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenCondition{Value: left, Lower: lower, Upper: upper}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		list, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		return &InCondition{Value: left, List: list}, nil
+	}
+	return nil, p.errorf("expect comparator, BETWEEN or IN")
+}
+
+// parseArgs 解析 (a, b, ...)
+func (p *parser) parseArgs() ([]Operand, error) {
+	if _, err := p.expect(tokLParen, "\"(\""); err != nil {
+		return nil, err
+	}
+	args := []Operand{}
+	for {
+		arg, err := p.parseOperand(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek().kind == tokComma {
+			p.next()
+			continue
+		}
+		break
+	}
+	if _, err := p.expect(tokRParen, "\")\""); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// parseOperand 解析操作数。update 为 true 时允许 if_not_exists 和 list_append
+func (p *parser) parseOperand(update bool) (Operand, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokValue:
+		p.next()
+		return &ValueOperand{Name: t.text}, nil
+	case tokName:
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return &PathOperand{Path: pth}, nil
+	case tokIdent:
+		if p.tokens[p.pos+1].kind == tokLParen {
+			return p.parseFunction(update)
+		}
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return &PathOperand{Path: pth}, nil
+	}
+	return nil, p.errorf("expect operand")
+}
+
+func (p *parser) parseFunction(update bool) (Operand, error) {
+	t := p.next()
+	switch {
+	case t.text == "size" && !update:
+	case (t.text == "if_not_exists" || t.text == "list_append") && update:
+	default:
+		return nil, fmt.Errorf("Invalid expression %q: Invalid function name; function: %s", p.expr, t.text)
+	}
+	if _, err := p.expect(tokLParen, "\"(\""); err != nil {
+		return nil, err
+	}
+	args := []Operand{}
+	for {
+		arg, err := p.parseOperand(update)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek().kind == tokComma {
+			p.next()
+			continue
+		}
+		break
+	}
+	if _, err := p.expect(tokRParen, "\")\""); err != nil {
+		return nil, err
+	}
+	argc := 2
+	if t.text == "size" {
+		argc = 1
+	}
+	if len(args) != argc {
+		return nil, fmt.Errorf("Invalid expression %q: Incorrect number of operands for operator or function; operator or function: %s, number of operands: %d", p.expr, t.text, len(args))
+	}
+	if t.text != "list_append" {
+		if _, ok := args[0].(*PathOperand); !ok {
+			return nil, fmt.Errorf("Invalid expression %q: Operator or function requires a document path; operator or function: %s", p.expr, t.text)
+		}
+	}
+	return &FunctionOperand{Name: t.text, Args: args}, nil
+}
+
+func (p *parser) parsePath() (Path, error) {
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	pth := Path{{Name: name}}
+	for {
+		switch p.peek().kind {
+		case tokDot:
+			p.next()
+			name, err := p.parseName()
+			if err != nil {
+				return nil, err
+			}
+			pth = append(pth, PathElem{Name: name})
+		case tokLBracket:
+			p.next()
+			t, err := p.expect(tokNumber, "list index")
+			if err != nil {
+				return nil, err
+			}
+			index, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, p.errorf("invalid list index")
+			}
+			if _, err := p.expect(tokRBracket, "\"]\""); err != nil {
+				return nil, err
+			}
+			pth = append(pth, PathElem{Index: index, IsIndex: true})
+		default:
+			return pth, nil
+		}
+	}
+}
+
+func (p *parser) parseName() (string, error) {
+	t := p.peek()
+	if t.kind != tokIdent && t.kind != tokName {
+		return "", p.errorf("expect attribute name")
+	}
+	p.next()
+	return t.text, nil
+}
+
+// ParseUpdate 解析 UpdateExpression
+func ParseUpdate(expr string) (*Update, error) {
+	p, err := newParser(expr)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokEOF {
+		return nil, fmt.Errorf("Invalid UpdateExpression: The expression can not be empty")
+	}
+	update := &Update{}
+	seen := map[string]bool{}
+	for p.peek().kind != tokEOF {
+		t := p.peek()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokIdent || seen[clause] {
+			return nil, p.errorf("expect SET, REMOVE, ADD or DELETE")
+		}
+		p.next()
+		seen[clause] = true
+		for {
+			switch clause {
+			case "SET":
+				pth, err := p.parsePath()
+				if err != nil {
+					return nil, err
+				}
+				if _, err := p.expect(tokEQ, "\"=\""); err != nil {
+					return nil, err
+				}
+				value, err := p.parseSetValue()
+				if err != nil {
+					return nil, err
+				}
+				update.Set = append(update.Set, &SetAction{Path: pth, Value: value})
+			case "REMOVE":
+				pth, err := p.parsePath()
+				if err != nil {
+					return nil, err
+				}
+				update.Remove = append(update.Remove, pth)
+			case "ADD", "DELETE":
+				pth, err := p.parsePath()
+				if err != nil {
+					return nil, err
+				}
+				v, err := p.expect(tokValue, "expression attribute value")
+				if err != nil {
+					return nil, err
+				}
+				action := &AddAction{Path: pth, Value: &ValueOperand{Name: v.text}}
+				if clause == "ADD" {
+					update.Add = append(update.Add, action)
+				} else {
+					update.Delete = append(update.Delete, action)
+				}
+			default:
+				return nil, fmt.Errorf("Invalid UpdateExpression %q: unknown clause %s", p.expr, t.text)
+			}
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	return update, nil
+}
+
+func (p *parser) parseSetValue() (Operand, error) {
+	left, err := p.parseOperand(true)
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind == tokPlus || t.kind == tokMinus {
+		p.next()
+		right, err := p.parseOperand(true)
+		if err != nil {
+			return nil, err
+		}
+		return &ArithOperand{Op: operators[t.kind], Left: left, Right: right}, nil
+	}
+	return left, nil
+}
+
+// ParseProjection 解析 ProjectionExpression，即逗号分隔的文档路径
+func ParseProjection(expr string) ([]Path, error) {
+	p, err := newParser(expr)
+	if err != nil {
+		return nil, err
+	}
+	paths := []Path{}
+	for {
+		pth, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, pth)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return paths, nil
+}
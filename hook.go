@@ -0,0 +1,407 @@
+package odm
+
+import (
+	"context"
+	"sync"
+)
+
+// Operation 的类型，与 DynamoDB 的操作名一致
+const (
+	OpPutItem                = "PutItem"
+	OpUpdateItem             = "UpdateItem"
+	OpGetItem                = "GetItem"
+	OpDeleteItem             = "DeleteItem"
+	OpQuery                  = "Query"
+	OpScan                   = "Scan"
+	OpBatchGetItem           = "BatchGetItem"
+	OpBatchWriteItem         = "BatchWriteItem"
+	OpTransactGetItems       = "TransactGetItems"
+	OpTransactWriteItems     = "TransactWriteItems"
+	OpCreateTable            = "CreateTable"
+	OpCreateTableIfNotExists = "CreateTableIfNotExists"
+	OpDropTable              = "DropTable"
+)
+
+// Operation 一次 Table 或 DialectDB 操作，供 Interceptor 读取。
+// 除 Item 外的参数只读，修改不会影响实际的请求
+type Operation struct {
+	// Kind 操作类型，如 OpPutItem
+	Kind string
+	// Dialect 方言的名称，即 Dialect.GetName
+	Dialect string
+	// TableName 操作的表名，批量操作和事务涉及多个表时为空，见 Requests
+	TableName string
+	HashKey   interface{}
+	RangeKey  interface{}
+	// Item PutItem 写入的 item，UpdateModel 的 model。Interceptor 可以替换它，例如写入前加密字段
+	Item Model
+	// UpdateExpr UpdateItem 的更新表达式
+	UpdateExpr string
+	// Option 操作的参数：*WriteOption、*GetOption、*QueryOption、*ScanOption、*TransactWriteOption、*TableMeta
+	Option interface{}
+	// Requests 批量操作和事务的请求：[]*BatchGet、[]*BatchWrite、[]*TransactGet、[]*TransactWrite
+	Requests interface{}
+	// OffsetKey Query、Scan 的分页位置，执行后为下一页的位置
+	OffsetKey Map
+	// Result 执行后填充的结果，批量读取和事务读取为 []interface{}
+	Result interface{}
+	// Unprocessed 批量操作执行后未处理的数据：[]*BatchGet、[]*BatchWrite
+	Unprocessed interface{}
+	// Retry 重试的次数，包括按 RetryPolicy 的重试和批量操作重试未处理的数据，0 表示第一次执行
+	Retry int
+	// Err 执行的结果，在 next 返回后有效，为内层 Interceptor 返回的错误
+	Err error
+	// ConsumedCapacity 执行后由方言填充的消耗容量，见 AddConsumedCapacity
+	ConsumedCapacity []*ConsumedCapacity
+
+	mu sync.Mutex
+}
+
+type operationKey struct{}
+
+// OperationFromContext 返回 ctx 所属的 Operation，不是经由 Interceptor 发起的请求返回 nil
+func OperationFromContext(ctx context.Context) *Operation {
+	op, _ := ctx.Value(operationKey{}).(*Operation)
+	return op
+}
+
+// TableNames 返回操作涉及的表名，批量操作和事务按出现的顺序去重
+func (op *Operation) TableNames() []string {
+	if op.TableName != "" {
+		return []string{op.TableName}
+	}
+	names := []string{}
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	switch requests := op.Requests.(type) {
+	case []*BatchGet:
+		for _, r := range requests {
+			add(r.TableName)
+		}
+	case []*BatchWrite:
+		for _, r := range requests {
+			add(r.TableName)
+		}
+	case []*TransactGet:
+		for _, r := range requests {
+			if r.TableName == "" && r.Meta != nil {
+				add(r.Meta.TableName)
+			} else {
+				add(r.TableName)
+			}
+		}
+	case []*TransactWrite:
+		for _, r := range requests {
+			switch {
+			case r.ConditionCheck != nil:
+				add(r.ConditionCheck.TableName)
+			case r.Put != nil:
+				add(r.Put.TableName)
+			case r.Update != nil:
+				add(r.Update.TableName)
+			case r.Delete != nil:
+				add(r.Delete.TableName)
+			}
+		}
+	}
+	return names
+}
+
+// AddConsumedCapacity 记录请求消耗的容量，供方言调用，可以并发调用
+func (op *Operation) AddConsumedCapacity(capacities ...*ConsumedCapacity) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.ConsumedCapacity = append(op.ConsumedCapacity, capacities...)
+}
+
+// Interceptor 包裹每一个操作，调用 next 继续执行，可以在前后加入日志、监控、鉴权等逻辑。
+// 传给 next 的 ctx 会用于之后的 Interceptor 和实际的请求
+// Example:
+//
+//	db.Use(func(ctx context.Context, op *odm.Operation, next func(context.Context) error) error {
+//		start := time.Now()
+//		err := next(ctx)
+//		log.Printf("%s %s %v %v", op.Kind, op.TableName, time.Since(start), err)
+//		return err
+//	})
+type Interceptor func(ctx context.Context, op *Operation, next func(context.Context) error) error
+
+// chain 注册的 Interceptor，WithContext 得到的副本共享
+type chain struct {
+	mu           sync.RWMutex
+	interceptors []Interceptor
+}
+
+func (c *chain) add(interceptors ...Interceptor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interceptors = append(c.interceptors[:len(c.interceptors):len(c.interceptors)], interceptors...)
+}
+
+// run 按注册顺序执行 Interceptor，最后执行 call。失败时按 RetryPolicy 重新执行，每次都经过 Interceptor
+func (db *hookDB) run(op *Operation, call func(context.Context) error) error {
+	ctx := db.context()
+	op.Dialect = db.dialect
+	op.Retry = retryFromContext(ctx)
+	policy := db.retry.ForOperation(op.Kind)
+	item := op.Item
+	for attempt := 0; ; attempt++ {
+		err := db.chain.run(ctx, op, call)
+		if !policy.ShouldRetry(attempt, err) {
+			return err
+		}
+		if err := sleep(ctx, policy.Delay(attempt)); err != nil {
+			return err
+		}
+		op.Retry++
+		op.Item = item
+		op.Unprocessed = nil
+		op.Err = nil
+		op.ConsumedCapacity = nil
+	}
+}
+
+func (c *chain) run(ctx context.Context, op *Operation, call func(context.Context) error) error {
+	c.mu.RLock()
+	interceptors := c.interceptors
+	c.mu.RUnlock()
+	var next func(i int) func(context.Context) error
+	next = func(i int) func(context.Context) error {
+		if i == len(interceptors) {
+			return func(ctx context.Context) error {
+				op.Err = call(context.WithValue(ctx, operationKey{}, op))
+				return op.Err
+			}
+		}
+		return func(ctx context.Context) error {
+			op.Err = interceptors[i](ctx, op, next(i+1))
+			return op.Err
+		}
+	}
+	return next(0)(ctx)
+}
+
+// Use 注册 Interceptor，之后通过 db 获得的 Table、事务和批量操作都会经过 Interceptor，
+// 先注册的在外层。第一次调用 Use 之前获得的 Table 不受影响，之后获得的 Table 和 WithContext 得到的副本共享全部 Interceptor
+func (db *ODMDB) Use(interceptors ...Interceptor) *ODMDB {
+	db.hooked().chain.add(interceptors...)
+	return db
+}
+
+// hooked 返回包装 DialectDB 的 hookDB，第一次调用时创建
+func (db *ODMDB) hooked() *hookDB {
+	hooked, ok := db.DialectDB.(*hookDB)
+	if !ok {
+		hooked = &hookDB{DialectDB: db.DialectDB, chain: &chain{}, ctx: db.ctx, dialect: db.dialect}
+		db.DialectDB = hooked
+	}
+	return hooked
+}
+
+// hookDB 在 DialectDB 的每个操作外执行 Interceptor，并按 retry 重试
+type hookDB struct {
+	DialectDB
+	chain   *chain
+	retry   *RetryPolicy
+	ctx     context.Context
+	dialect string
+}
+
+func (db *hookDB) context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+	return db.ctx
+}
+
+// Context 返回当前使用的 context
+func (db *hookDB) Context() context.Context {
+	return db.context()
+}
+
+// with 返回使用 ctx 的底层 DialectDB
+func (db *hookDB) with(ctx context.Context) DialectDB {
+	if ctx == db.context() {
+		return db.DialectDB
+	}
+	return db.DialectDB.WithContext(ctx)
+}
+
+func (db *hookDB) WithContext(ctx context.Context) DialectDB {
+	return &hookDB{DialectDB: db.DialectDB.WithContext(ctx), chain: db.chain, retry: db.retry, ctx: ctx, dialect: db.dialect}
+}
+
+func (db *hookDB) GetDialectTable(meta *TableMeta) Table {
+	return &hookTable{Table: db.DialectDB.GetDialectTable(meta), db: db, tableName: meta.TableName}
+}
+
+func (db *hookDB) CreateTable(meta *TableMeta) error {
+	op := &Operation{Kind: OpCreateTable, TableName: meta.TableName, Option: meta}
+	return db.run(op, func(ctx context.Context) error {
+		return db.with(ctx).CreateTable(meta)
+	})
+}
+
+func (db *hookDB) CreateTableIfNotExists(meta *TableMeta) error {
+	op := &Operation{Kind: OpCreateTableIfNotExists, TableName: meta.TableName, Option: meta}
+	return db.run(op, func(ctx context.Context) error {
+		return db.with(ctx).CreateTableIfNotExists(meta)
+	})
+}
+
+func (db *hookDB) DropTable(tableName string) error {
+	op := &Operation{Kind: OpDropTable, TableName: tableName}
+	return db.run(op, func(ctx context.Context) error {
+		return db.with(ctx).DropTable(tableName)
+	})
+}
+
+func (db *hookDB) BatchGetItem(options []*BatchGet, unprocessedItems *[]*BatchGet, results ...interface{}) error {
+	op := &Operation{Kind: OpBatchGetItem, Requests: options, Result: results}
+	err := db.run(op, func(ctx context.Context) error {
+		unprocessed := []*BatchGet{}
+		err := db.with(ctx).BatchGetItem(options, &unprocessed, results...)
+		op.Unprocessed = unprocessed
+		return err
+	})
+	// 只返回最后一次执行未处理的数据
+	if unprocessed, ok := op.Unprocessed.([]*BatchGet); ok && unprocessedItems != nil {
+		*unprocessedItems = append(*unprocessedItems, unprocessed...)
+	}
+	return err
+}
+
+func (db *hookDB) BatchWriteItem(options []*BatchWrite, unprocessedItems *[]*BatchWrite) error {
+	op := &Operation{Kind: OpBatchWriteItem, Requests: options}
+	err := db.run(op, func(ctx context.Context) error {
+		unprocessed := []*BatchWrite{}
+		err := db.with(ctx).BatchWriteItem(options, &unprocessed)
+		op.Unprocessed = unprocessed
+		return err
+	})
+	// 只返回最后一次执行未处理的数据
+	if unprocessed, ok := op.Unprocessed.([]*BatchWrite); ok && unprocessedItems != nil {
+		*unprocessedItems = append(*unprocessedItems, unprocessed...)
+	}
+	return err
+}
+
+func (db *hookDB) TransactGetItems(gets []*TransactGet, results ...Model) error {
+	op := &Operation{Kind: OpTransactGetItems, Requests: gets, Result: results}
+	return db.run(op, func(ctx context.Context) error {
+		return db.with(ctx).TransactGetItems(gets, results...)
+	})
+}
+
+func (db *hookDB) TransactWriteItems(writes []*TransactWrite, opt *TransactWriteOption) error {
+	op := &Operation{Kind: OpTransactWriteItems, Requests: writes, Option: opt}
+	return db.run(op, func(ctx context.Context) error {
+		return db.with(ctx).TransactWriteItems(writes, opt)
+	})
+}
+
+// hookTable 在 Table 的每个操作外执行 Interceptor。
+// UpdateFields、Query 等组合操作经由 hookTable 调用底层操作，每个底层操作都会经过 Interceptor
+type hookTable struct {
+	Table
+	db        *hookDB
+	tableName string
+}
+
+// with 返回使用 ctx 的底层 Table
+func (t *hookTable) with(ctx context.Context) Table {
+	if ctx == t.db.context() {
+		return t.Table
+	}
+	return t.Table.WithContext(ctx)
+}
+
+func (t *hookTable) run(op *Operation, call func(table Table) error) error {
+	op.TableName = t.tableName
+	return t.db.run(op, func(ctx context.Context) error {
+		return call(t.with(ctx))
+	})
+}
+
+func (t *hookTable) GetDB() DialectDB {
+	return t.db
+}
+
+func (t *hookTable) WithContext(ctx context.Context) Table {
+	db := t.db.WithContext(ctx).(*hookDB)
+	return &hookTable{Table: t.Table.WithContext(ctx), db: db, tableName: t.tableName}
+}
+
+func (t *hookTable) PutItem(item Model, cond *WriteOption, result Model) error {
+	op := &Operation{Kind: OpPutItem, Item: item, Option: cond, Result: result}
+	return t.run(op, func(table Table) error {
+		return table.PutItem(op.Item, cond, result)
+	})
+}
+
+func (t *hookTable) UpdateItem(hashKey interface{}, rangeKey interface{}, updateExpr string, opt *WriteOption, result Model) error {
+	op := &Operation{Kind: OpUpdateItem, HashKey: hashKey, RangeKey: rangeKey, UpdateExpr: updateExpr, Option: opt, Result: result}
+	return t.run(op, func(table Table) error {
+		return table.UpdateItem(hashKey, rangeKey, updateExpr, opt, result)
+	})
+}
+
+func (t *hookTable) UpdateFields(hashKey interface{}, rangeKey interface{}, fields Map, opt *WriteOption, result Model) error {
+	return UpdateFields(t, hashKey, rangeKey, fields, opt, result)
+}
+
+func (t *hookTable) UpdateModel(model Model, fields []string, opt *WriteOption, result Model) error {
+	// 字段名由方言决定，交给底层 Table 执行，记为一次 UpdateItem
+	op := &Operation{Kind: OpUpdateItem, Item: model, Option: opt, Result: result}
+	if hashKey, rangeKey, err := ModelKey(model); err == nil {
+		op.HashKey, op.RangeKey = hashKey, rangeKey
+	}
+	return t.run(op, func(table Table) error {
+		return table.UpdateModel(op.Item, fields, opt, result)
+	})
+}
+
+func (t *hookTable) GetItem(hashKey interface{}, rangeKey interface{}, opt *GetOption, result Model) error {
+	op := &Operation{Kind: OpGetItem, HashKey: hashKey, RangeKey: rangeKey, Option: opt, Result: result}
+	return t.run(op, func(table Table) error {
+		return table.GetItem(hashKey, rangeKey, opt, result)
+	})
+}
+
+func (t *hookTable) DeleteItem(hashKey interface{}, rangeKey interface{}, opt *WriteOption, result Model) error {
+	op := &Operation{Kind: OpDeleteItem, HashKey: hashKey, RangeKey: rangeKey, Option: opt, Result: result}
+	return t.run(op, func(table Table) error {
+		return table.DeleteItem(hashKey, rangeKey, opt, result)
+	})
+}
+
+func (t *hookTable) Query(query *QueryOption, offsetKey Map, results interface{}) error {
+	op := &Operation{Kind: OpQuery, Option: query, OffsetKey: offsetKey, Result: results}
+	return t.run(op, func(table Table) error {
+		return table.Query(query, offsetKey, results)
+	})
+}
+
+func (t *hookTable) Scan(opt *ScanOption, offsetKey Map, results interface{}) error {
+	op := &Operation{Kind: OpScan, Option: opt, OffsetKey: offsetKey, Result: results}
+	return t.run(op, func(table Table) error {
+		return table.Scan(opt, offsetKey, results)
+	})
+}
+
+func (t *hookTable) QueryIter(query *QueryOption) *Iterator {
+	return NewQueryIter(t, query)
+}
+
+func (t *hookTable) ScanIter(opt *ScanOption) *Iterator {
+	return NewScanIter(t, opt)
+}
+
+func (t *hookTable) Count(query *QueryOption) (*CountResult, error) {
+	return CountItems(t, query)
+}
@@ -0,0 +1,127 @@
+package odm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ctxTable 记录底层 Table 收到的 context，WithContext 得到的副本记录到 root
+type ctxTable struct {
+	Table
+	root *ctxTable
+	ctx  context.Context
+	gets int
+	last context.Context
+}
+
+func (t *ctxTable) WithContext(ctx context.Context) Table {
+	root := t.root
+	if root == nil {
+		root = t
+	}
+	return &ctxTable{root: root, ctx: ctx}
+}
+
+func (t *ctxTable) GetItem(hashKey interface{}, rangeKey interface{}, opt *GetOption, result Model) error {
+	root := t.root
+	if root == nil {
+		root = t
+	}
+	root.gets++
+	root.last = t.ctx
+	return nil
+}
+
+type ctxDB struct {
+	DialectDB
+	table *ctxTable
+}
+
+func (db *ctxDB) WithContext(ctx context.Context) DialectDB {
+	return db
+}
+
+func (db *ctxDB) GetDialectTable(meta *TableMeta) Table {
+	return db.table
+}
+
+func TestODMDB_Use(t *testing.T) {
+	newDB := func() (*ODMDB, *ctxTable) {
+		table := &ctxTable{}
+		return &ODMDB{DialectDB: &ctxDB{table: table}}, table
+	}
+	trace := func(calls *[]string, name string) Interceptor {
+		return func(ctx context.Context, op *Operation, next func(context.Context) error) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+
+	t.Run("Order", func(t *testing.T) {
+		db, table := newDB()
+		calls := []string{}
+		db.Use(trace(&calls, "a"), trace(&calls, "b")).Use(trace(&calls, "c"))
+		assert.NoError(t, db.Table(&Book{}).GetItem("Tom", "Hello", nil, nil))
+		assert.Equal(t, []string{"a before", "b before", "c before", "c after", "b after", "a after"}, calls)
+		assert.Equal(t, 1, table.gets)
+	})
+
+	t.Run("Short-circuit", func(t *testing.T) {
+		db, table := newDB()
+		calls := []string{}
+		denied := errors.New("denied")
+		db.Use(trace(&calls, "a"), func(ctx context.Context, op *Operation, next func(context.Context) error) error {
+			return denied
+		}, trace(&calls, "c"))
+		var seen error
+		db.Use(func(ctx context.Context, op *Operation, next func(context.Context) error) error {
+			seen = op.Err
+			return next(ctx)
+		})
+		assert.Equal(t, denied, db.Table(&Book{}).GetItem("Tom", "Hello", nil, nil))
+		assert.Equal(t, []string{"a before", "a after"}, calls)
+		assert.Nil(t, seen)
+		assert.Equal(t, 0, table.gets)
+	})
+
+	t.Run("WithContext", func(t *testing.T) {
+		type key struct{}
+		db, table := newDB()
+		var op *Operation
+		var value interface{}
+		db.Use(func(ctx context.Context, o *Operation, next func(context.Context) error) error {
+			op, value = o, ctx.Value(key{})
+			return next(context.WithValue(ctx, key{}, "inner"))
+		})
+		ctx := context.WithValue(context.Background(), key{}, "outer")
+		assert.NoError(t, db.WithContext(ctx).Table(&Book{}).GetItem("Tom", "Hello", nil, nil))
+		assert.Equal(t, "outer", value)
+		assert.Equal(t, OpGetItem, op.Kind)
+		assert.Equal(t, "book", op.TableName)
+		// 底层 Table 收到 Interceptor 传给 next 的 context
+		assert.Equal(t, "inner", table.last.Value(key{}))
+
+		// Use 之后 WithContext 得到的副本共享 Interceptor
+		op = nil
+		assert.NoError(t, db.Table(&Book{}).WithContext(ctx).GetItem("Tom", "Hello", nil, nil))
+		assert.NotNil(t, op)
+		assert.Equal(t, 2, table.gets)
+	})
+}
+
+func TestOperation_TableNames(t *testing.T) {
+	assert.Equal(t, []string{"book"}, (&Operation{TableName: "book"}).TableNames())
+	assert.Equal(t, []string{"account", "bag"}, (&Operation{Requests: []*TransactWrite{
+		{Update: &Update{TableName: "account"}},
+		{Put: &Put{TableName: "bag"}},
+		{Delete: &Delete{TableName: "account"}},
+	}}).TableNames())
+	assert.Equal(t, []string{"book"}, (&Operation{Requests: []*TransactGet{{Meta: &TableMeta{TableName: "book"}}}}).TableNames())
+	assert.Equal(t, []string{"a", "b"}, (&Operation{Requests: []*BatchWrite{{TableName: "a"}, {TableName: "b"}}}).TableNames())
+	assert.Empty(t, (&Operation{}).TableNames())
+}
@@ -0,0 +1,129 @@
+package odm
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Iterator 逐个读取 Query、Scan 的结果，自动跟随 LastEvaluatedKey 翻页，
+// 直到读取到 Limit 个满足条件的 item 或者没有更多数据。Limit 为 0 时读取全部数据。
+// Example:
+//
+//	it := table.QueryIter(query)
+//	book := Book{}
+//	for it.Next(&book) {
+//		fmt.Println(book)
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+//	nextPage := it.LastKey()
+type Iterator struct {
+	read      func(limit int64, offsetKey Map, page interface{}) error
+	limit     int64
+	returned  int64
+	offsetKey Map
+	page      reflect.Value
+	index     int
+	// done 没有更多的分页
+	done bool
+	err  error
+}
+
+// NewQueryIter 创建 Query 的迭代器，供方言实现 Table.QueryIter
+func NewQueryIter(table Table, query *QueryOption) *Iterator {
+	if query == nil {
+		return &Iterator{err: errors.New("QueryOptions is required for Table.QueryIter")}
+	}
+	opt := *query
+	return &Iterator{
+		limit: query.Limit,
+		read: func(limit int64, offsetKey Map, page interface{}) error {
+			opt.Limit = limit
+			return table.Query(&opt, offsetKey, page)
+		},
+	}
+}
+
+// NewScanIter 创建 Scan 的迭代器，供方言实现 Table.ScanIter
+func NewScanIter(table Table, scan *ScanOption) *Iterator {
+	opt := ScanOption{}
+	if scan != nil {
+		opt = *scan
+	}
+	return &Iterator{
+		limit: opt.Limit,
+		read: func(limit int64, offsetKey Map, page interface{}) error {
+			opt.Limit = limit
+			return table.Scan(&opt, offsetKey, page)
+		},
+	}
+}
+
+// StartFrom 从 offsetKey 之后开始读取，offsetKey 通常是上一次迭代的 LastKey，需在 Next 之前调用
+func (it *Iterator) StartFrom(offsetKey Map) *Iterator {
+	if len(offsetKey) > 0 {
+		it.offsetKey = Map{}
+		for k, v := range offsetKey {
+			it.offsetKey[k] = v
+		}
+	}
+	return it
+}
+
+// Next 将下一个 item 填充到 result，result 为指向 item 的指针，每次调用的类型需相同。
+// 没有更多数据或出错时返回 false
+func (it *Iterator) Next(result interface{}) bool {
+	if it.err != nil || (it.limit > 0 && it.returned >= it.limit) {
+		return false
+	}
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		it.err = errors.New("Iterator.Next requires a pointer")
+		return false
+	}
+	if it.page.IsValid() && it.page.Type().Elem() != rv.Type().Elem() {
+		it.err = errors.New("Iterator.Next requires the same type of result")
+		return false
+	}
+	for !it.page.IsValid() || it.index >= it.page.Len() {
+		if it.done {
+			return false
+		}
+		if it.offsetKey == nil {
+			it.offsetKey = Map{}
+		}
+		var limit int64
+		if it.limit > 0 {
+			limit = it.limit - it.returned
+		}
+		page := reflect.New(reflect.SliceOf(rv.Type().Elem()))
+		if it.err = it.read(limit, it.offsetKey, page.Interface()); it.err != nil {
+			return false
+		}
+		it.page = page.Elem()
+		it.index = 0
+		it.done = len(it.offsetKey) == 0
+	}
+	rv.Elem().Set(it.page.Index(it.index))
+	it.index++
+	it.returned++
+	return true
+}
+
+// Err 返回迭代过程中的错误
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// LastKey 返回继续读取的位置，可以传给 StartFrom 或 Query 的 offsetKey。
+// 在 Next 返回 false 后有效，为空表示已经读取完毕
+func (it *Iterator) LastKey() Map {
+	if it.page.IsValid() && it.index < it.page.Len() {
+		return nil
+	}
+	if it.done {
+		return Map{}
+	}
+	return it.offsetKey
+}
@@ -0,0 +1,860 @@
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"git.devops.com/go/odm"
+	"git.devops.com/go/odm/codec"
+	"git.devops.com/go/odm/expression"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+var dbName = "memory"
+
+// schemaName 内存数据库沿用 DynamoDB 的数据模型，字段名与 dynamo 方言保持一致
+var schemaName = "dynamodb"
+
+const errCodeValidation = "ValidationException"
+
+// item 以 DynamoDB 数据模型存储的一条记录
+type item = expression.Item
+
+func init() {
+	odm.RegisterDialect(dbName, &memoryDialect{})
+}
+
+type memoryDialect struct {
+}
+
+var sharedDBs = struct {
+	sync.Mutex
+	dbs map[string]*DB
+}{dbs: make(map[string]*DB)}
+
+// Open 打开内存数据库。connectString 为数据库名，同名数据库在进程内共享数据；
+// 为空时每次打开一个独立的数据库。
+func (d *memoryDialect) Open(connectString string) (odm.DialectDB, error) {
+	name := strings.TrimSpace(connectString)
+	if name == "" {
+		return NewDB(), nil
+	}
+	sharedDBs.Lock()
+	defer sharedDBs.Unlock()
+	db := sharedDBs.dbs[name]
+	if db == nil {
+		db = NewDB()
+		sharedDBs.dbs[name] = db
+	}
+	// 共享数据，SchemaPolicy 各自独立
+	return db.withContext(nil), nil
+}
+
+func (d *memoryDialect) GetName() string {
+	return dbName
+}
+
+// NewDB 创建一个空的内存数据库，默认允许创建和删除任意表
+func NewDB() *DB {
+	return &DB{
+		store: &store{
+			tables: make(map[string]*tableData),
+			tokens: make(map[string]*clientRequest),
+		},
+		schema: &odm.SchemaPolicy{AllowCreateTable: true, AllowDropTable: true},
+	}
+}
+
+// DB 是纯 Go 实现的内存数据库，按 DynamoDB 的语义实现 odm.DialectDB，用于测试和本地开发。
+type DB struct {
+	*store
+	ctx    context.Context
+	schema *odm.SchemaPolicy
+}
+
+// store 数据库的数据，WithContext 得到的 DB 共享同一个 store
+type store struct {
+	mu     sync.RWMutex
+	tables map[string]*tableData
+	// 已提交事务的 ClientRequestToken
+	tokens map[string]*clientRequest
+}
+
+// WithContext 返回使用 ctx 的 DB，ctx 被取消后操作直接返回 ctx.Err()
+func (db *DB) WithContext(ctx context.Context) odm.DialectDB {
+	return db.withContext(ctx)
+}
+
+// SetConsumedCapacityHandler 内存数据库不消耗容量，handler 不会被调用
+func (db *DB) SetConsumedCapacityHandler(handler odm.ConsumedCapacityHandler) {
+}
+
+// SetSchemaPolicy 设置允许的表结构操作，为 nil 时不允许创建和删除表
+func (db *DB) SetSchemaPolicy(policy *odm.SchemaPolicy) {
+	db.schema = policy
+}
+
+func (db *DB) withContext(ctx context.Context) *DB {
+	return &DB{
+		store:  db.store,
+		ctx:    ctx,
+		schema: db.schema,
+	}
+}
+
+// Context 返回当前使用的 context
+func (db *DB) Context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+	return db.ctx
+}
+
+// tableData 一张表的数据
+type tableData struct {
+	meta     *odm.TableMeta
+	hashKey  string
+	rangeKey string
+	items    map[string]item
+}
+
+// 错误码对应的错误类型，与 dynamo 方言一致
+var errorKinds = map[string]error{
+	errCodeValidation:                                    odm.ErrValidation,
+	dynamodb.ErrCodeResourceNotFoundException:            odm.ErrTableNotFound,
+	dynamodb.ErrCodeConditionalCheckFailedException:      odm.ErrConditionFailed,
+	dynamodb.ErrCodeIdempotentParameterMismatchException: odm.ErrValidation,
+}
+
+func newError(code string, format string, args ...interface{}) error {
+	return odm.NewError(errorKinds[code], code, fmt.Sprintf(format, args...), nil)
+}
+
+func validationError(format string, args ...interface{}) error {
+	return newError(errCodeValidation, format, args...)
+}
+
+func (db *DB) getFieldName(f *odm.FieldDefine) string {
+	return f.GetDBFieldName(schemaName)
+}
+
+func (db *DB) GetDialectTable(meta *odm.TableMeta) odm.Table {
+	return &Table{
+		db:        db,
+		TableMeta: *meta,
+	}
+}
+
+func (db *DB) CreateTable(meta *odm.TableMeta) error {
+	if err := db.Context().Err(); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.createTable(meta)
+}
+
+func (db *DB) createTable(meta *odm.TableMeta) error {
+	if err := db.schema.CheckCreateTable(meta.TableName); err != nil {
+		return err
+	}
+	if meta.PK == nil {
+		return validationError("PK is required to create table %s", meta.TableName)
+	}
+	if db.tables[meta.TableName] != nil {
+		return newError(dynamodb.ErrCodeResourceInUseException, "Cannot create preexisting table: %s", meta.TableName)
+	}
+	m := *meta
+	td := &tableData{
+		meta:    &m,
+		hashKey: db.getFieldName(meta.PK),
+		items:   make(map[string]item),
+	}
+	if meta.SK != nil {
+		td.rangeKey = db.getFieldName(meta.SK)
+	}
+	for _, index := range meta.Indexes {
+		if index.Global && index.PK == nil {
+			return validationError("PK is required to create global secondary index %s", index.IndexName)
+		}
+		if !index.Global && index.SK == nil {
+			return validationError("SK is required to create local secondary index %s", index.IndexName)
+		}
+	}
+	db.tables[meta.TableName] = td
+	return nil
+}
+
+func (db *DB) CreateTableIfNotExists(meta *odm.TableMeta) error {
+	if err := db.Context().Err(); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.tables[meta.TableName] != nil {
+		return nil
+	}
+	return db.createTable(meta)
+}
+
+func (db *DB) DropTable(tableName string) error {
+	if err := db.Context().Err(); err != nil {
+		return err
+	}
+	if err := db.schema.CheckDropTable(tableName); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.tables[tableName] == nil {
+		return newError(dynamodb.ErrCodeResourceNotFoundException, "Cannot do operations on a non-existent table: %s", tableName)
+	}
+	delete(db.tables, tableName)
+	return nil
+}
+
+// GetTableMeta 返回表的主键信息
+func (db *DB) GetTableMeta(tableName string) (*odm.TableMeta, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	td, err := db.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return td.meta, nil
+}
+
+func (db *DB) table(tableName string) (*tableData, error) {
+	if tableName == "" {
+		return nil, validationError("TableName is required")
+	}
+	td := db.tables[tableName]
+	if td == nil {
+		return nil, newError(dynamodb.ErrCodeResourceNotFoundException, "Cannot do operations on a non-existent table: %s", tableName)
+	}
+	return td, nil
+}
+
+func (db *DB) Close() {
+	// Nothing to do.
+}
+
+// key 根据 hashKey、rangeKey 生成主键
+func (td *tableData) key(hashKey interface{}, rangeKey interface{}) (item, error) {
+	key := odm.Map{td.hashKey: hashKey}
+	if td.rangeKey != "" && rangeKey != nil {
+		key[td.rangeKey] = rangeKey
+	}
+	return td.keyFromMap(key)
+}
+
+// keyFromMap 将 odm.Map 形式的主键转换为 item，并校验是否与表结构一致
+func (td *tableData) keyFromMap(key odm.Map) (item, error) {
+	av, err := dynamodbattribute.MarshalMap(key)
+	if err != nil {
+		return nil, err
+	}
+	expect := 1
+	if td.rangeKey != "" {
+		expect = 2
+	}
+	if len(av) != expect {
+		return nil, validationError("The provided key element does not match the schema")
+	}
+	if _, err := td.encodeKey(av); err != nil {
+		return nil, err
+	}
+	return av, nil
+}
+
+// keyOf 提取 item 的主键属性
+func (td *tableData) keyOf(it item) item {
+	key := item{td.hashKey: it[td.hashKey]}
+	if td.rangeKey != "" {
+		key[td.rangeKey] = it[td.rangeKey]
+	}
+	return key
+}
+
+// encodeKey 将主键编码为字符串，作为内部存储的索引
+func (td *tableData) encodeKey(it item) (string, error) {
+	hash, err := encodeKeyValue(td.hashKey, it[td.hashKey])
+	if err != nil {
+		return "", err
+	}
+	if td.rangeKey == "" {
+		return hash, nil
+	}
+	rng, err := encodeKeyValue(td.rangeKey, it[td.rangeKey])
+	if err != nil {
+		return "", err
+	}
+	return hash + "|" + rng, nil
+}
+
+func encodeKeyValue(name string, v *dynamodb.AttributeValue) (string, error) {
+	if v == nil {
+		return "", validationError("One of the required keys was not given a value: %s", name)
+	}
+	switch {
+	case v.S != nil:
+		if *v.S == "" {
+			return "", validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: %s", name)
+		}
+		return "S" + base64.StdEncoding.EncodeToString([]byte(*v.S)), nil
+	case v.N != nil:
+		n, err := expression.NormalizeNumber(*v.N)
+		if err != nil {
+			return "", validationError(err.Error())
+		}
+		return "N" + n, nil
+	case v.B != nil:
+		return "B" + base64.StdEncoding.EncodeToString(v.B), nil
+	}
+	return "", validationError("Invalid attribute value type for key %s", name)
+}
+
+func (td *tableData) get(key item) item {
+	k, err := td.encodeKey(key)
+	if err != nil {
+		return nil
+	}
+	return td.items[k]
+}
+
+func (td *tableData) put(it item) error {
+	k, err := td.encodeKey(it)
+	if err != nil {
+		return err
+	}
+	td.items[k] = it
+	return nil
+}
+
+func (td *tableData) delete(key item) {
+	k, err := td.encodeKey(key)
+	if err == nil {
+		delete(td.items, k)
+	}
+}
+
+// segment 计算 item 在并行扫描中所属的段，同一 hashKey 的 item 总在同一段
+func (td *tableData) segment(it item, totalSegments int64) int64 {
+	k, _ := encodeKeyValue(td.hashKey, it[td.hashKey])
+	h := fnv.New32a()
+	h.Write([]byte(k))
+	return int64(h.Sum32()) % totalSegments
+}
+
+// sortedItems 按 hashKey、rangeKey 升序返回所有 item
+func (td *tableData) sortedItems() []item {
+	items := make([]item, 0, len(td.items))
+	for _, it := range td.items {
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return td.compareKey(items[i], items[j]) < 0
+	})
+	return items
+}
+
+func (td *tableData) compareKey(a, b item) int {
+	c, _ := expression.Compare(a[td.hashKey], b[td.hashKey])
+	if c != 0 || td.rangeKey == "" {
+		return c
+	}
+	c, _ = expression.Compare(a[td.rangeKey], b[td.rangeKey])
+	return c
+}
+
+// checkUpdateKey 禁止 UpdateExpression 修改主键
+func (td *tableData) checkUpdateKey(e *expression.Env, update *expression.Update) error {
+	for _, p := range update.Paths() {
+		name, err := e.Name(p[0].Name)
+		if err != nil {
+			return validationError(err.Error())
+		}
+		if name == td.hashKey || name == td.rangeKey {
+			return validationError("One or more parameter values were invalid: Cannot update attribute %s. This attribute is part of the key", name)
+		}
+	}
+	return nil
+}
+
+// newEnv 构造表达式求值环境
+func newEnv(names map[string]string, values odm.Map) (*expression.Env, error) {
+	e := &expression.Env{Names: names}
+	if values != nil {
+		av, err := dynamodbattribute.MarshalMap(values)
+		if err != nil {
+			return nil, err
+		}
+		e.Values = av
+	}
+	return e, nil
+}
+
+func writeOptionEnv(opt *odm.WriteOption) (*expression.Env, error) {
+	if opt == nil {
+		return &expression.Env{}, nil
+	}
+	return newEnv(opt.NameParams, opt.ValueParams)
+}
+
+// checkCondition 校验写操作的条件表达式，old 为 nil 表示 item 不存在
+func checkCondition(e *expression.Env, expr string, old item) error {
+	if expr == "" {
+		return nil
+	}
+	cond, err := expression.ParseCondition(expr)
+	if err != nil {
+		return validationError(err.Error())
+	}
+	if old == nil {
+		old = item{}
+	}
+	ok, err := e.EvalCondition(old, cond)
+	if err != nil {
+		return validationError(err.Error())
+	}
+	if !ok {
+		return newError(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed")
+	}
+	return nil
+}
+
+// projection 根据 Select 裁剪 item
+func projection(e *expression.Env, selectExpr string, it item) (item, error) {
+	if selectExpr == "" || it == nil {
+		return expression.CopyItem(it), nil
+	}
+	paths, err := expression.ParseProjection(selectExpr)
+	if err != nil {
+		return nil, validationError(err.Error())
+	}
+	result, err := e.Project(it, paths)
+	if err != nil {
+		return nil, validationError(err.Error())
+	}
+	return result, nil
+}
+
+// marshalItems 将 model 的 slice 转换为 item 列表
+func marshalItems(models interface{}) ([]item, error) {
+	if models == nil {
+		return nil, nil
+	}
+	v := reflect.ValueOf(models)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, validationError("PutItems should be a slice, but got %T", models)
+	}
+	items := make([]item, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		it, err := codec.Marshal(v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+func (db *DB) BatchGetItem(options []*odm.BatchGet, unprocessedItems *[]*odm.BatchGet, results ...interface{}) error {
+	if err := db.Context().Err(); err != nil {
+		return err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if len(results) < len(options) {
+		return validationError("BatchGetItem requires a result for each option, %d options but %d results", len(options), len(results))
+	}
+	seen := map[string]bool{}
+	responses := make([][]item, len(options))
+	for i, opt := range options {
+		if seen[opt.TableName] {
+			return validationError("BatchGetItem options TableName <%s> duplicated", opt.TableName)
+		}
+		seen[opt.TableName] = true
+		td, err := db.table(opt.TableName)
+		if err != nil {
+			return err
+		}
+		e, err := newEnv(opt.NameParams, nil)
+		if err != nil {
+			return err
+		}
+		responses[i] = []item{}
+		keyItems := []item{}
+		for _, key := range opt.Keys {
+			keyItem, err := td.keyFromMap(key)
+			if err != nil {
+				return err
+			}
+			keyItems = append(keyItems, keyItem)
+		}
+		for _, pair := range opt.KeyPairs {
+			keyItem, err := td.key(pair.HashKey, pair.RangeKey)
+			if err != nil {
+				return err
+			}
+			keyItems = append(keyItems, keyItem)
+		}
+		keys := map[string]bool{}
+		for _, keyItem := range keyItems {
+			k, _ := td.encodeKey(keyItem)
+			if keys[k] {
+				return validationError("Provided list of item keys contains duplicates")
+			}
+			keys[k] = true
+			it := td.get(keyItem)
+			if it == nil {
+				continue
+			}
+			it, err = projection(e, opt.Select, it)
+			if err != nil {
+				return err
+			}
+			responses[i] = append(responses[i], it)
+		}
+	}
+	for i, items := range responses {
+		if err := codec.UnmarshalList(items, results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) BatchWriteItem(options []*odm.BatchWrite, unprocessedItems *[]*odm.BatchWrite) error {
+	if err := db.Context().Err(); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	type write struct {
+		td  *tableData
+		put item
+		del item
+	}
+	writes := []write{}
+	seen := map[string]bool{}
+	for _, opt := range options {
+		td, err := db.table(opt.TableName)
+		if err != nil {
+			return err
+		}
+		puts, err := marshalItems(opt.PutItems)
+		if err != nil {
+			return err
+		}
+		for _, it := range puts {
+			k, err := td.encodeKey(it)
+			if err != nil {
+				return err
+			}
+			if seen[opt.TableName+"/"+k] {
+				return validationError("Provided list of item keys contains duplicates")
+			}
+			seen[opt.TableName+"/"+k] = true
+			writes = append(writes, write{td: td, put: it})
+		}
+		for _, key := range opt.DeleteKeys {
+			keyItem, err := td.keyFromMap(key)
+			if err != nil {
+				return err
+			}
+			k, _ := td.encodeKey(keyItem)
+			if seen[opt.TableName+"/"+k] {
+				return validationError("Provided list of item keys contains duplicates")
+			}
+			seen[opt.TableName+"/"+k] = true
+			writes = append(writes, write{td: td, del: keyItem})
+		}
+	}
+	for _, w := range writes {
+		if w.put != nil {
+			_ = w.td.put(w.put)
+		} else {
+			w.td.delete(w.del)
+		}
+	}
+	return nil
+}
+
+func (db *DB) TransactGetItems(gets []*odm.TransactGet, results ...odm.Model) error {
+	if err := db.Context().Err(); err != nil {
+		return err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if len(results) < len(gets) {
+		return validationError("TransactGetItems requires a result for each get, %d gets but %d results", len(gets), len(results))
+	}
+	found := make([]item, len(gets))
+	for i, get := range gets {
+		tableName := get.TableName
+		if tableName == "" && get.Meta != nil {
+			tableName = get.Meta.TableName
+		}
+		td, err := db.table(tableName)
+		if err != nil {
+			return err
+		}
+		var key item
+		if get.Key != nil {
+			key, err = td.keyFromMap(get.Key)
+		} else {
+			key, err = td.key(get.HashKey, get.RangeKey)
+		}
+		if err != nil {
+			return err
+		}
+		e, err := newEnv(get.NameParams, nil)
+		if err != nil {
+			return err
+		}
+		found[i], err = projection(e, get.Select, td.get(key))
+		if err != nil {
+			return err
+		}
+		if get.Found != nil {
+			*get.Found = found[i] != nil
+		}
+	}
+	for i, it := range found {
+		if it == nil || results[i] == nil {
+			continue
+		}
+		if err := codec.Unmarshal(it, results[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transactWrite 事务中单个写操作的执行计划
+type transactWrite struct {
+	td        *tableData
+	key       item
+	condition string
+	env       *expression.Env
+	// 执行后的 item，nil 表示删除
+	result item
+	// 仅做条件检查，不写入
+	checkOnly    bool
+	returnValues string
+}
+
+func (db *DB) planTransactWrite(write *odm.TransactWrite) (*transactWrite, error) {
+	plan := &transactWrite{}
+	var opt *odm.WriteOption
+	var err error
+	switch {
+	case write.Put != nil:
+		if plan.td, err = db.table(write.Put.TableName); err != nil {
+			return nil, err
+		}
+		if plan.result, err = codec.Marshal(write.Put.Item); err != nil {
+			return nil, err
+		}
+		if _, err = plan.td.encodeKey(plan.result); err != nil {
+			return nil, err
+		}
+		plan.key = plan.td.keyOf(plan.result)
+		opt = write.Put.WriteOption
+		plan.returnValues = write.Put.ReturnValuesOnConditionCheckFailure
+	case write.Update != nil:
+		if plan.td, err = db.table(write.Update.TableName); err != nil {
+			return nil, err
+		}
+		if plan.key, err = plan.td.key(write.Update.HashKey, write.Update.RangeKey); err != nil {
+			return nil, err
+		}
+		opt = write.Update.WriteOption
+		plan.returnValues = write.Update.ReturnValuesOnConditionCheckFailure
+	case write.Delete != nil:
+		if plan.td, err = db.table(write.Delete.TableName); err != nil {
+			return nil, err
+		}
+		if plan.key, err = plan.td.key(write.Delete.HashKey, write.Delete.RangeKey); err != nil {
+			return nil, err
+		}
+		opt = write.Delete.WriteOption
+		plan.returnValues = write.Delete.ReturnValuesOnConditionCheckFailure
+	case write.ConditionCheck != nil:
+		check := write.ConditionCheck
+		if check.Condition == "" {
+			return nil, validationError("ConditionCheck requires a condition expression")
+		}
+		if plan.td, err = db.table(check.TableName); err != nil {
+			return nil, err
+		}
+		if check.Key != nil {
+			plan.key, err = plan.td.keyFromMap(check.Key)
+		} else {
+			plan.key, err = plan.td.key(check.HashKey, check.RangeKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		opt = &odm.WriteOption{
+			Condition:   check.Condition,
+			NameParams:  check.NameParams,
+			ValueParams: check.ValueParams,
+		}
+		plan.checkOnly = true
+		plan.returnValues = check.ReturnValuesOnConditionCheckFailure
+	default:
+		return nil, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+	if opt != nil {
+		plan.condition = opt.Condition
+	}
+	if plan.env, err = writeOptionEnv(opt); err != nil {
+		return nil, err
+	}
+	if write.Update != nil {
+		update, err := expression.ParseUpdate(write.Update.Expression)
+		if err != nil {
+			return nil, validationError(err.Error())
+		}
+		if err = plan.td.checkUpdateKey(plan.env, update); err != nil {
+			return nil, err
+		}
+		old := plan.td.get(plan.key)
+		if old == nil {
+			old = expression.CopyItem(plan.key)
+		}
+		if plan.result, _, err = plan.env.ApplyUpdate(old, update); err != nil {
+			return nil, validationError(err.Error())
+		}
+	}
+	return plan, nil
+}
+
+func (db *DB) TransactWriteItems(writes []*odm.TransactWrite, opt *odm.TransactWriteOption) error {
+	if err := db.Context().Err(); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var token string
+	if opt != nil {
+		token = opt.ClientRequestToken
+	}
+	digest, err := db.checkClientRequestToken(token, writes)
+	if err != nil {
+		return err
+	}
+	if token != "" && digest == "" {
+		// 相同的事务已经提交过
+		return nil
+	}
+	plans := make([]*transactWrite, len(writes))
+	targets := map[string]bool{}
+	for i, write := range writes {
+		plan, err := db.planTransactWrite(write)
+		if err != nil {
+			return err
+		}
+		k, _ := plan.td.encodeKey(plan.key)
+		target := plan.td.meta.TableName + "/" + k
+		if targets[target] {
+			return validationError("Transaction request cannot include multiple operations on one item")
+		}
+		targets[target] = true
+		plans[i] = plan
+	}
+	reasons := make([]*dynamodb.CancellationReason, len(plans))
+	canceled := false
+	for i, plan := range plans {
+		reasons[i] = &dynamodb.CancellationReason{Code: aws.String("None")}
+		old := plan.td.get(plan.key)
+		err := checkCondition(plan.env, plan.condition, old)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, odm.ErrConditionFailed) {
+			return err
+		}
+		canceled = true
+		reasons[i].Code = aws.String("ConditionalCheckFailed")
+		reasons[i].Message = aws.String("The conditional request failed")
+		if plan.returnValues == dynamodb.ReturnValuesOnConditionCheckFailureAllOld && old != nil {
+			reasons[i].Item = expression.CopyItem(old)
+		}
+	}
+	if canceled {
+		codes := make([]string, len(reasons))
+		for i, reason := range reasons {
+			codes[i] = *reason.Code
+		}
+		return codec.TransactionCanceledError(&dynamodb.TransactionCanceledException{
+			CancellationReasons: reasons,
+			Message_:            aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+		})
+	}
+	for _, plan := range plans {
+		if plan.checkOnly {
+			continue
+		}
+		if plan.result == nil {
+			plan.td.delete(plan.key)
+		} else {
+			_ = plan.td.put(plan.result)
+		}
+	}
+	if token != "" {
+		db.tokens[token] = &clientRequest{digest: digest, at: time.Now()}
+	}
+	return nil
+}
+
+// clientRequestTTL ClientRequestToken 的有效期，与 DynamoDB 一致
+const clientRequestTTL = 10 * time.Minute
+
+// clientRequest 记录已成功提交的事务，用于 ClientRequestToken 的幂等检查
+type clientRequest struct {
+	digest string
+	at     time.Time
+}
+
+// checkClientRequestToken 返回本次请求的摘要。token 已提交过且参数一致时返回空摘要，表示无需再次执行
+func (db *DB) checkClientRequestToken(token string, writes []*odm.TransactWrite) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	data, err := json.Marshal(writes)
+	if err != nil {
+		return "", err
+	}
+	digest := string(data)
+	now := time.Now()
+	for t, req := range db.tokens {
+		if now.Sub(req.at) > clientRequestTTL {
+			delete(db.tokens, t)
+		}
+	}
+	req := db.tokens[token]
+	if req == nil {
+		return digest, nil
+	}
+	if req.digest != digest {
+		return "", newError(dynamodb.ErrCodeIdempotentParameterMismatchException, "The request uses the same client token as a previous, but non-identical request")
+	}
+	return "", nil
+}
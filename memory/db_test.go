@@ -0,0 +1,412 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Account struct {
+	Id      int   `odm:"PK" json:"id"`
+	Balance int64 `json:"balance"`
+}
+
+type Bag struct {
+	Uid       int    `odm:"PK" json:"uid"`
+	ProductId string `odm:"SK" json:"product_id"`
+	Count     int    `json:"count"`
+}
+
+func TestOpen(t *testing.T) {
+	db1, err := odm.Open("memory", "shared")
+	assert.NoError(t, err)
+	db2, err := odm.Open("memory", "shared")
+	assert.NoError(t, err)
+	err = db1.Table(&Account{}).PutItem(&Account{Id: 1, Balance: 10}, nil, nil)
+	assert.NoError(t, err)
+	account := &Account{}
+	err = db2.Table("account").GetItem(1, nil, nil, account)
+	assert.NoError(t, err)
+	assert.Equal(t, &Account{Id: 1, Balance: 10}, account)
+
+	db3, err := odm.Open("memory", "")
+	assert.NoError(t, err)
+	err = db3.Table("account").GetItem(1, nil, nil, account)
+	assert.Error(t, err)
+}
+
+func TestDB_DropTable(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	accounts, err := db.ResetTable(&Account{})
+	assert.NoError(t, err)
+	assert.NoError(t, accounts.PutItem(&Account{Id: 1}, nil, nil))
+	assert.NoError(t, db.DropTable("account"))
+	assert.Error(t, db.DropTable("account"))
+	assert.Error(t, db.CreateTable(&odm.TableMeta{TableName: "no_pk"}))
+}
+
+func TestDB_SchemaPolicy(t *testing.T) {
+	shared, err := odm.Open("memory", "schema")
+	assert.NoError(t, err)
+	_, err = shared.ResetTable(&Account{})
+	assert.NoError(t, err)
+
+	// 同名数据库的 SchemaPolicy 互不影响
+	db, err := odm.Open("memory", "schema", odm.WithSchemaPolicy(&odm.SchemaPolicy{AllowCreateTable: true, TablePrefix: "test_"}))
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(db.DropTable("account"), odm.ErrSchemaNotAllowed))
+	_, err = db.ResetTable(&Account{})
+	assert.True(t, errors.Is(err, odm.ErrSchemaNotAllowed))
+	assert.True(t, errors.Is(db.CreateTable(&odm.TableMeta{TableName: "bag", PK: &odm.FieldDefine{Type: "N"}}), odm.ErrSchemaNotAllowed))
+	assert.NoError(t, db.WithContext(context.Background()).CreateTable(&odm.TableMeta{TableName: "test_bag", PK: &odm.FieldDefine{SchemaFieldName: map[string]string{"dynamodb": "uid"}, Type: "N"}}))
+	// 表已存在时不需要创建
+	assert.NoError(t, db.CreateTableIfNotExists(odm.GetModelMeta(&Account{})))
+	assert.NoError(t, shared.DropTable("account"))
+}
+
+func TestDB_WithContext(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	accounts, err := db.ResetTable(&Account{})
+	assert.NoError(t, err)
+	assert.NoError(t, accounts.PutItem(&Account{Id: 1, Balance: 10}, nil, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := accounts.WithContext(ctx)
+	assert.Equal(t, context.Canceled, canceled.PutItem(&Account{Id: 2}, nil, nil))
+	assert.Equal(t, context.Canceled, canceled.GetItem(1, nil, nil, &Account{}))
+	err = db.WithContext(ctx).Transact().Update("account", 1, nil, "SET balance = :b", &odm.WriteOption{
+		ValueParams: odm.Map{":b": 20},
+	}, nil).Commit()
+	assert.Equal(t, context.Canceled, err)
+
+	// 原来的 Table 与 DB 不受影响，且共享数据
+	account := &Account{}
+	assert.NoError(t, accounts.GetItem(1, nil, nil, account))
+	assert.Equal(t, int64(10), account.Balance)
+	assert.NoError(t, db.WithContext(context.Background()).Table("account").GetItem(1, nil, nil, account))
+}
+
+func TestDB_Use(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	ops := []string{}
+	db.Use(func(ctx context.Context, op *odm.Operation, next func(context.Context) error) error {
+		err := next(ctx)
+		ops = append(ops, op.Kind+" "+op.TableName)
+		assert.Equal(t, err, op.Err)
+		return err
+	})
+	accounts, err := db.ResetTable(&Account{})
+	assert.NoError(t, err)
+	assert.NoError(t, accounts.PutItem(&Account{Id: 1, Balance: 10}, nil, nil))
+	assert.NoError(t, accounts.UpdateFields(1, nil, odm.Map{"balance": 20}, nil, nil))
+	assert.Error(t, accounts.PutItem(&Account{Id: 1}, &odm.WriteOption{Condition: "attribute_not_exists(id)"}, nil))
+	assert.NoError(t, db.Transact().Update("account", 1, nil, "SET balance = :b", &odm.WriteOption{
+		ValueParams: odm.Map{":b": 30},
+	}, nil).Commit())
+	assert.Equal(t, []string{
+		"DropTable account",
+		"CreateTableIfNotExists account",
+		"PutItem account",
+		"UpdateItem account",
+		"PutItem account",
+		"TransactWriteItems ",
+	}, ops)
+
+	// 后注册的在内层，可以替换 ctx 和 Item，也可以直接返回错误
+	denied := errors.New("denied")
+	db.Use(func(ctx context.Context, op *odm.Operation, next func(context.Context) error) error {
+		switch op.Kind {
+		case odm.OpDeleteItem:
+			return denied
+		case odm.OpPutItem:
+			op.Item = &Account{Id: 2, Balance: 99}
+		case odm.OpGetItem:
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			return next(canceled)
+		}
+		return next(ctx)
+	})
+	ops = ops[:0]
+	assert.Equal(t, denied, accounts.DeleteItem(1, nil, nil, nil))
+	assert.NoError(t, accounts.PutItem(&Account{Id: 3}, nil, nil))
+	assert.Equal(t, context.Canceled, accounts.GetItem(2, nil, nil, &Account{}))
+	assert.Equal(t, []string{"DeleteItem account", "PutItem account", "GetItem account"}, ops)
+
+	// 新注册的 Interceptor 对之前获得的 Table 同样生效，WithContext 得到的副本共享 Interceptor
+	accountList := []Account{}
+	count, err := db.WithContext(context.Background()).Table(&Account{}).Count(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count.Count)
+	assert.NoError(t, accounts.WithContext(context.Background()).Scan(nil, nil, &accountList))
+	assert.Equal(t, []Account{{Id: 1, Balance: 30}, {Id: 2, Balance: 99}}, accountList)
+	assert.Equal(t, []string{"Query account", "Scan account"}, ops[3:])
+}
+
+func TestDB_BatchItem(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	db.ResetTable(&Account{})
+	db.ResetTable(&Bag{})
+	err := db.BatchWriteItem([]*odm.BatchWrite{
+		{
+			TableName: "account",
+			PutItems:  []Account{{Id: 1, Balance: 1}, {Id: 2, Balance: 2}, {Id: 3, Balance: 3}},
+		},
+		{
+			TableName: "bag",
+			PutItems:  []*Bag{{Uid: 1, ProductId: "a", Count: 1}, {Uid: 1, ProductId: "b", Count: 2}},
+		},
+	}, nil)
+	assert.NoError(t, err)
+	err = db.BatchWriteItem([]*odm.BatchWrite{
+		{
+			TableName:  "account",
+			DeleteKeys: []odm.Map{{"id": 3}},
+		},
+	}, nil)
+	assert.NoError(t, err)
+
+	accounts := []Account{}
+	bags := []Bag{}
+	unprocessed := []*odm.BatchGet{}
+	err = db.BatchGetItem([]*odm.BatchGet{
+		{
+			TableName: "account",
+			Keys:      []odm.Map{{"id": 1}, {"id": 2}, {"id": 3}},
+		},
+		{
+			TableName:  "bag",
+			Select:     "#count",
+			NameParams: map[string]string{"#count": "count"},
+			Keys:       []odm.Map{{"uid": 1, "product_id": "b"}},
+		},
+	}, &unprocessed, &accounts, &bags)
+	assert.NoError(t, err)
+	assert.Empty(t, unprocessed)
+	assert.Equal(t, []Account{{Id: 1, Balance: 1}, {Id: 2, Balance: 2}}, accounts)
+	assert.Equal(t, []Bag{{Count: 2}}, bags)
+	t.Run("KeyPairs", func(t *testing.T) {
+		accounts := []Account{}
+		bags := []Bag{}
+		err := db.BatchGetItemWithRetry([]*odm.BatchGet{
+			{TableName: "account", KeyPairs: []odm.KeyPair{{HashKey: 2}}},
+			{TableName: "bag", Keys: []odm.Map{{"uid": 1, "product_id": "a"}}, KeyPairs: []odm.KeyPair{{HashKey: 1, RangeKey: "b"}}},
+		}, time.Now().Add(time.Second), nil, &accounts, &bags)
+		assert.NoError(t, err)
+		assert.Equal(t, []Account{{Id: 2, Balance: 2}}, accounts)
+		assert.Equal(t, []Bag{{Uid: 1, ProductId: "a", Count: 1}, {Uid: 1, ProductId: "b", Count: 2}}, bags)
+	})
+}
+
+func TestDB_TransactItems(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	accounts, _ := db.ResetTable(&Account{})
+	db.ResetTable(&Bag{})
+	accounts.PutItem(&Account{Id: 1, Balance: 100}, nil, nil)
+	pay := func(fee int) error {
+		return db.TransactWriteItems([]*odm.TransactWrite{
+			{
+				Update: &odm.Update{
+					TableName:  "account",
+					HashKey:    1,
+					Expression: "SET balance = balance - :fee",
+					WriteOption: &odm.WriteOption{
+						Condition:   "balance >= :fee",
+						ValueParams: odm.Map{":fee": fee},
+					},
+				},
+			},
+			{
+				Update: &odm.Update{
+					TableName:  "bag",
+					HashKey:    1,
+					RangeKey:   "iPhone",
+					Expression: "ADD #count :count",
+					WriteOption: &odm.WriteOption{
+						NameParams:  map[string]string{"#count": "count"},
+						ValueParams: odm.Map{":count": 1},
+					},
+				},
+			},
+		}, nil)
+	}
+	assert.NoError(t, pay(60))
+	err := pay(60)
+	canceled, ok := err.(*odm.TransactionCanceledError)
+	assert.True(t, ok)
+	assert.Equal(t, "ConditionalCheckFailed", canceled.Reasons[0].Code)
+	assert.Equal(t, "None", canceled.Reasons[1].Code)
+	assert.Equal(t, []int{0}, canceled.Failed())
+	assert.True(t, errors.Is(err, odm.ErrConditionFailed))
+	var raw *dynamodb.TransactionCanceledException
+	assert.True(t, errors.As(err, &raw))
+
+	account := &Account{}
+	bag := &Bag{}
+	err = db.TransactGetItems([]*odm.TransactGet{
+		{TableName: "account", HashKey: 1},
+		{TableName: "bag", Key: odm.Map{"uid": 1, "product_id": "iPhone"}},
+	}, account, bag)
+	assert.NoError(t, err)
+	assert.Equal(t, &Account{Id: 1, Balance: 40}, account)
+	assert.Equal(t, &Bag{Uid: 1, ProductId: "iPhone", Count: 1}, bag)
+}
+
+func TestDB_TransactWrite(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	accounts, _ := db.ResetTable(&Account{})
+	bags, _ := db.ResetTable(&Bag{})
+	accounts.PutItem(&Account{Id: 1, Balance: 100}, nil, nil)
+	bags.PutItem(&Bag{Uid: 1, ProductId: "old", Count: 1}, nil, nil)
+	t.Run("Put, Delete and Check", func(t *testing.T) {
+		err := db.Transact().Check("account", 1, nil, &odm.WriteOption{
+			Condition:   "balance >= :min",
+			ValueParams: odm.Map{":min": 50},
+		}, nil).Put(&Bag{Uid: 1, ProductId: "new", Count: 1}, &odm.WriteOption{
+			Condition: "attribute_not_exists(uid)",
+		}, nil).Delete("bag", 1, "old", nil, nil).Commit()
+		assert.NoError(t, err)
+		items := []Bag{}
+		err = bags.Query(&odm.QueryOption{
+			KeyFilter:   "uid = :uid",
+			ValueParams: odm.Map{":uid": 1},
+		}, nil, &items)
+		assert.NoError(t, err)
+		assert.Equal(t, []Bag{{Uid: 1, ProductId: "new", Count: 1}}, items)
+	})
+	t.Run("Check failed", func(t *testing.T) {
+		failValue := &Account{}
+		err := db.Transact().Check("account", 1, nil, &odm.WriteOption{
+			Condition:   "balance >= :min",
+			ValueParams: odm.Map{":min": 500},
+		}, failValue).Delete("bag", 1, "new", nil, &Bag{}).Commit()
+		canceled, ok := err.(*odm.TransactionCanceledError)
+		assert.True(t, ok)
+		assert.Equal(t, "ConditionalCheckFailed", canceled.Reasons[0].Code)
+		assert.Equal(t, odm.Map{"id": float64(1), "balance": float64(100)}, canceled.Reasons[0].Item)
+		assert.Equal(t, &Account{Id: 1, Balance: 100}, failValue)
+		bag := &Bag{}
+		assert.NoError(t, bags.GetItem(1, "new", nil, bag))
+		assert.Equal(t, 1, bag.Count)
+	})
+	t.Run("Check without condition", func(t *testing.T) {
+		err := db.Transact().Check("account", 1, nil, nil, nil).Commit()
+		assert.Error(t, err)
+	})
+	t.Run("ClientRequestToken", func(t *testing.T) {
+		pay := func(token string, fee int) error {
+			return db.Transact().Update("account", 1, nil, "SET balance = balance - :fee", &odm.WriteOption{
+				ValueParams: odm.Map{":fee": fee},
+			}, nil).ClientRequestToken(token).Commit()
+		}
+		assert.NoError(t, pay("order-1", 10))
+		assert.NoError(t, pay("order-1", 10))
+		account := &Account{}
+		assert.NoError(t, accounts.GetItem(1, nil, nil, account))
+		assert.Equal(t, int64(90), account.Balance)
+		err := pay("order-1", 20)
+		aerr, ok := err.(awserr.Error)
+		assert.True(t, ok)
+		assert.Equal(t, dynamodb.ErrCodeIdempotentParameterMismatchException, aerr.Code())
+	})
+}
+
+func TestDB_TransactGet(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	accounts, _ := db.ResetTable(&Account{})
+	bags, _ := db.ResetTable(&Bag{})
+	accounts.PutItem(&Account{Id: 1, Balance: 100}, nil, nil)
+	bags.PutItem(&Bag{Uid: 1, ProductId: "sku", Count: 2}, nil, nil)
+	account := &Account{}
+	bag := &Bag{}
+	missing := &Bag{}
+	tx := db.Transact().Get(account, 1).Get(bag, 1, "sku", &odm.GetOption{
+		Select:     "#count",
+		NameParams: map[string]string{"#count": "count"},
+	}).Get(missing, 1, "none")
+	assert.NoError(t, tx.Commit())
+	assert.Equal(t, &Account{Id: 1, Balance: 100}, account)
+	assert.Equal(t, &Bag{Count: 2}, bag)
+	assert.Equal(t, &Bag{}, missing)
+	assert.True(t, tx.Found(account))
+	assert.True(t, tx.Found(bag))
+	assert.False(t, tx.Found(missing))
+}
+
+func ExampleDB_TransactWriteItems() {
+	db, _ := odm.Open("memory", "")
+	accounts, _ := db.ResetTable(&Account{})
+	bags, _ := db.ResetTable(&Bag{})
+	uid := 10
+	accounts.PutItem(&Account{
+		Id:      uid,
+		Balance: 100000,
+	}, nil, nil)
+	cart := map[string]int{
+		"iPhone": 1,
+		"Huawei": 1,
+	}
+	fee := 9000
+	writeItems := []*odm.TransactWrite{
+		{
+			Update: &odm.Update{
+				TableName:  "account",
+				HashKey:    uid,
+				Expression: "SET balance=balance-:fee",
+				WriteOption: &odm.WriteOption{
+					Condition: "balance >= :fee",
+					ValueParams: odm.Map{
+						":fee": fee,
+					},
+				},
+			},
+		},
+	}
+	for pid, count := range cart {
+		writeItems = append(writeItems, &odm.TransactWrite{
+			Update: &odm.Update{
+				TableName:  "bag",
+				HashKey:    uid,
+				RangeKey:   pid,
+				Expression: "ADD #count :count",
+				WriteOption: &odm.WriteOption{
+					NameParams: map[string]string{
+						"#count": "count",
+					},
+					ValueParams: odm.Map{
+						":count": count,
+					},
+				},
+			},
+		})
+	}
+	err := db.TransactWriteItems(writeItems, nil)
+	if err != nil {
+		fmt.Printf("Fail to execute transaction %v", err)
+		return
+	}
+	bagItems := []Bag{}
+	bags.Query(&odm.QueryOption{
+		KeyFilter: "uid=:uid",
+		ValueParams: odm.Map{
+			":uid": uid,
+		},
+		Limit: 10,
+	}, nil, &bagItems)
+	fmt.Println(bagItems)
+	account := &Account{}
+	accounts.GetItem(uid, nil, nil, account)
+	fmt.Println(account.Balance)
+	// Output:
+	// [{10 Huawei 1} {10 iPhone 1}]
+	// 91000
+}
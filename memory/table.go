@@ -0,0 +1,475 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"git.devops.com/go/odm"
+	"git.devops.com/go/odm/codec"
+	"git.devops.com/go/odm/expression"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Table of memory implementation.
+type Table struct {
+	odm.TableMeta
+	db *DB
+}
+
+// GetDB of current table
+func (t *Table) GetDB() odm.DialectDB {
+	return t.db
+}
+
+// WithContext 返回使用 ctx 的 Table
+func (t *Table) WithContext(ctx context.Context) odm.Table {
+	return &Table{
+		TableMeta: t.TableMeta,
+		db:        t.db.withContext(ctx),
+	}
+}
+
+// begin 加锁并返回表数据，调用方需在操作完成后调用 unlock。
+// 与 dynamo 方言一致：TableMeta 未初始化时使用数据库中已有的表，否则表不存在时自动创建。
+// 表结构以 td.meta 为准，begin 不修改 t，同一个 Table 可以被并发使用。
+func (t *Table) begin(write bool) (td *tableData, unlock func(), err error) {
+	if err = t.db.Context().Err(); err != nil {
+		return nil, nil, err
+	}
+	if t.PK != nil {
+		if err = t.db.CreateTableIfNotExists(&t.TableMeta); err != nil {
+			return nil, nil, err
+		}
+	}
+	if write {
+		t.db.mu.Lock()
+		unlock = t.db.mu.Unlock
+	} else {
+		t.db.mu.RLock()
+		unlock = t.db.mu.RUnlock
+	}
+	td, err = t.db.table(t.TableName)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return td, unlock, nil
+}
+
+// PutItem put a item, will replace entire item. OLD will fill in result
+func (t *Table) PutItem(model odm.Model, cond *odm.WriteOption, result odm.Model) error {
+	td, unlock, err := t.begin(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	it, err := codec.Marshal(model)
+	if err != nil {
+		return err
+	}
+	if _, err = td.encodeKey(it); err != nil {
+		return err
+	}
+	e, err := writeOptionEnv(cond)
+	if err != nil {
+		return err
+	}
+	old := td.get(it)
+	if cond != nil {
+		if err = checkCondition(e, cond.Condition, old); err != nil {
+			return err
+		}
+	}
+	if err = td.put(it); err != nil {
+		return err
+	}
+	if result != nil && old != nil {
+		return codec.Unmarshal(expression.CopyItem(old), result)
+	}
+	return nil
+}
+
+// UpdateItem attributes. result will be filled with UPDATED_NEW attributes.
+func (t *Table) UpdateItem(hashKey interface{}, rangeKey interface{}, updateExpr string, cond *odm.WriteOption, result odm.Model) error {
+	td, unlock, err := t.begin(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	key, err := td.key(hashKey, rangeKey)
+	if err != nil {
+		return err
+	}
+	e, err := writeOptionEnv(cond)
+	if err != nil {
+		return err
+	}
+	update, err := expression.ParseUpdate(updateExpr)
+	if err != nil {
+		return validationError(err.Error())
+	}
+	if err = td.checkUpdateKey(e, update); err != nil {
+		return err
+	}
+	old := td.get(key)
+	if cond != nil {
+		if err = checkCondition(e, cond.Condition, old); err != nil {
+			return err
+		}
+	}
+	base := old
+	if base == nil {
+		base = expression.CopyItem(key)
+	}
+	it, updated, err := e.ApplyUpdate(base, update)
+	if err != nil {
+		return validationError(err.Error())
+	}
+	if err = td.put(it); err != nil {
+		return err
+	}
+	if result != nil {
+		attrs := item{}
+		for _, name := range updated {
+			if v, ok := it[name]; ok {
+				attrs[name] = expression.CopyValue(v)
+			}
+		}
+		return codec.Unmarshal(attrs, result)
+	}
+	return nil
+}
+
+// UpdateFields update attributes by map, nil value will be removed
+func (t *Table) UpdateFields(hashKey interface{}, rangeKey interface{}, fields odm.Map, cond *odm.WriteOption, result odm.Model) error {
+	return odm.UpdateFields(t, hashKey, rangeKey, fields, cond, result)
+}
+
+// UpdateModel update non-empty or listed fields of model
+func (t *Table) UpdateModel(model odm.Model, fields []string, cond *odm.WriteOption, result odm.Model) error {
+	return codec.UpdateModel(t, model, fields, cond, result)
+}
+
+// GetItem get an item
+func (t *Table) GetItem(hashKey interface{}, rangeKey interface{}, opt *odm.GetOption, result odm.Model) error {
+	td, unlock, err := t.begin(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	key, err := td.key(hashKey, rangeKey)
+	if err != nil {
+		return err
+	}
+	it := td.get(key)
+	if opt != nil {
+		e, err := newEnv(opt.NameParams, nil)
+		if err != nil {
+			return err
+		}
+		if it, err = projection(e, opt.Select, it); err != nil {
+			return err
+		}
+	}
+	if it == nil {
+		return odm.ErrNotFound
+	}
+	if result != nil {
+		return codec.Unmarshal(expression.CopyItem(it), result)
+	}
+	return nil
+}
+
+// DeleteItem returns deleted item if result provide
+func (t *Table) DeleteItem(hashKey interface{}, rangeKey interface{}, cond *odm.WriteOption, result odm.Model) error {
+	td, unlock, err := t.begin(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	key, err := td.key(hashKey, rangeKey)
+	if err != nil {
+		return err
+	}
+	e, err := writeOptionEnv(cond)
+	if err != nil {
+		return err
+	}
+	old := td.get(key)
+	if cond != nil {
+		if err = checkCondition(e, cond.Condition, old); err != nil {
+			return err
+		}
+	}
+	td.delete(key)
+	if result == nil {
+		return nil
+	}
+	if old == nil {
+		return odm.ErrNotFound
+	}
+	return codec.Unmarshal(old, result)
+}
+
+// view 是 Query、Scan 读取的表或索引。
+// 索引中只包含具有索引主键属性的 item，按索引主键排序，索引主键相同时再按表的主键排序
+type view struct {
+	td       *tableData
+	hashKey  string
+	rangeKey string
+	index    bool
+}
+
+// view 返回 indexName 对应的视图，indexName 为空时返回表本身
+func (td *tableData) view(indexName string) (*view, error) {
+	if indexName == "" {
+		return &view{td: td, hashKey: td.hashKey, rangeKey: td.rangeKey}, nil
+	}
+	index := td.meta.GetIndex(indexName)
+	if index == nil {
+		return nil, validationError("The table does not have the specified index: %s", indexName)
+	}
+	v := &view{td: td, hashKey: td.hashKey, index: true}
+	if index.Global {
+		v.hashKey = index.PK.GetDBFieldName(schemaName)
+	}
+	if index.SK != nil {
+		v.rangeKey = index.SK.GetDBFieldName(schemaName)
+	}
+	return v, nil
+}
+
+// items 按视图的主键升序返回所有 item
+func (v *view) items() []item {
+	if !v.index {
+		return v.td.sortedItems()
+	}
+	items := []item{}
+	for _, it := range v.td.items {
+		if it[v.hashKey] == nil || (v.rangeKey != "" && it[v.rangeKey] == nil) {
+			continue
+		}
+		items = append(items, it)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return v.compare(items[i], items[j]) < 0
+	})
+	return items
+}
+
+func (v *view) compare(a, b item) int {
+	c, _ := expression.Compare(a[v.hashKey], b[v.hashKey])
+	if c == 0 && v.rangeKey != "" {
+		c, _ = expression.Compare(a[v.rangeKey], b[v.rangeKey])
+	}
+	if c == 0 && v.index {
+		c = v.td.compareKey(a, b)
+	}
+	return c
+}
+
+// keyOf 提取 item 在视图中的主键，索引的主键包含表的主键
+func (v *view) keyOf(it item) item {
+	key := v.td.keyOf(it)
+	key[v.hashKey] = it[v.hashKey]
+	if v.rangeKey != "" {
+		key[v.rangeKey] = it[v.rangeKey]
+	}
+	return key
+}
+
+// keyCondition 从 KeyConditionExpression 中拆分出 hashKey 的值和 rangeKey 的条件
+func (v *view) keyCondition(e *expression.Env, expr string) (*dynamodb.AttributeValue, []expression.Condition, error) {
+	cond, err := expression.ParseCondition(expr)
+	if err != nil {
+		return nil, nil, validationError(err.Error())
+	}
+	hashValue, rangeConds, err := e.KeyCondition(cond, v.hashKey, v.rangeKey)
+	if err != nil {
+		return nil, nil, validationError(err.Error())
+	}
+	return hashValue, rangeConds, nil
+}
+
+func (v *view) afterOffset(it item, offset item, desc bool) bool {
+	if offset == nil {
+		return true
+	}
+	c := v.compare(it, offset)
+	if desc {
+		return c < 0
+	}
+	return c > 0
+}
+
+// read 对候选 item 进行分页、过滤、投影，并更新 offsetKey。results 为 *odm.CountResult 时只计数
+func (t *Table) read(v *view, candidates []item, query *odm.QueryOption, e *expression.Env, offsetKey odm.Map, results interface{}) error {
+	var filter expression.Condition
+	var err error
+	if query.Filter != "" {
+		if filter, err = expression.ParseCondition(query.Filter); err != nil {
+			return validationError(err.Error())
+		}
+	}
+	count, isCount := results.(*odm.CountResult)
+	var lastKey item
+	var scanned int64
+	matched := []item{}
+	for i, it := range candidates {
+		if query.Limit > 0 && int64(i) >= query.Limit {
+			lastKey = v.keyOf(candidates[i-1])
+			break
+		}
+		scanned++
+		if filter != nil {
+			ok, err := e.EvalCondition(it, filter)
+			if err != nil {
+				return validationError(err.Error())
+			}
+			if !ok {
+				continue
+			}
+		}
+		if !isCount {
+			if it, err = projection(e, query.Select, it); err != nil {
+				return err
+			}
+		}
+		matched = append(matched, it)
+	}
+	if offsetKey != nil {
+		for k := range offsetKey {
+			delete(offsetKey, k)
+		}
+		if lastKey != nil {
+			if err = codec.UnmarshalKey(lastKey, offsetKey); err != nil {
+				return err
+			}
+		}
+	}
+	if isCount {
+		count.Count = int64(len(matched))
+		count.ScannedCount = scanned
+		return nil
+	}
+	return codec.UnmarshalList(matched, results)
+}
+
+// Scan 扫描全表，按主键升序返回。指定 TotalSegments 时按 hashKey 的哈希值分段
+func (t *Table) Scan(opt *odm.ScanOption, offsetKey odm.Map, results interface{}) error {
+	if opt == nil {
+		opt = &odm.ScanOption{}
+	}
+	if opt.TotalSegments < 0 || opt.Segment < 0 || (opt.TotalSegments == 0 && opt.Segment != 0) {
+		return validationError("The Segment parameter is required but was not present in the request when parameter TotalSegments is present")
+	}
+	if opt.TotalSegments > 0 && opt.Segment >= opt.TotalSegments {
+		return validationError("The Segment parameter is zero-based and must be less than parameter TotalSegments: Segment: %d is not less than TotalSegments: %d", opt.Segment, opt.TotalSegments)
+	}
+	td, unlock, err := t.begin(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	v, err := td.view(opt.IndexName)
+	if err != nil {
+		return err
+	}
+	e, err := newEnv(opt.NameParams, opt.ValueParams)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetItem(offsetKey)
+	if err != nil {
+		return err
+	}
+	candidates := []item{}
+	for _, it := range v.items() {
+		if opt.TotalSegments > 0 && td.segment(it, opt.TotalSegments) != opt.Segment {
+			continue
+		}
+		if v.afterOffset(it, offset, false) {
+			candidates = append(candidates, it)
+		}
+	}
+	return t.read(v, candidates, &opt.QueryOption, e, offsetKey, results)
+}
+
+// Query and fill in items, offsetKey will be replaced after query
+func (t *Table) Query(query *odm.QueryOption, offsetKey odm.Map, results interface{}) error {
+	if query == nil {
+		return errors.New("QueryOptions is required for Table.Query, ")
+	}
+	if query.KeyFilter == "" {
+		return t.Scan(&odm.ScanOption{QueryOption: *query}, offsetKey, results)
+	}
+	td, unlock, err := t.begin(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	v, err := td.view(query.IndexName)
+	if err != nil {
+		return err
+	}
+	e, err := newEnv(query.NameParams, query.ValueParams)
+	if err != nil {
+		return err
+	}
+	hashValue, rangeConds, err := v.keyCondition(e, query.KeyFilter)
+	if err != nil {
+		return err
+	}
+	offset, err := offsetItem(offsetKey)
+	if err != nil {
+		return err
+	}
+	items := v.items()
+	if query.Desc {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	candidates := []item{}
+	for _, it := range items {
+		if !expression.Equal(it[v.hashKey], hashValue) || !v.afterOffset(it, offset, query.Desc) {
+			continue
+		}
+		ok := true
+		for _, c := range rangeConds {
+			if ok, err = e.EvalCondition(it, c); err != nil {
+				return validationError(err.Error())
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			candidates = append(candidates, it)
+		}
+	}
+	return t.read(v, candidates, query, e, offsetKey, results)
+}
+
+func offsetItem(offsetKey odm.Map) (item, error) {
+	if len(offsetKey) == 0 {
+		return nil, nil
+	}
+	return codec.MarshalKey(offsetKey)
+}
+
+// QueryIter returns an iterator which follows LastEvaluatedKey until Limit items are read
+func (t *Table) QueryIter(query *odm.QueryOption) *odm.Iterator {
+	return odm.NewQueryIter(t, query)
+}
+
+// ScanIter returns an iterator which follows LastEvaluatedKey until Limit items are read
+func (t *Table) ScanIter(opt *odm.ScanOption) *odm.Iterator {
+	return odm.NewScanIter(t, opt)
+}
+
+// Count counts items matching query with Select COUNT, following LastEvaluatedKey
+func (t *Table) Count(query *odm.QueryOption) (*odm.CountResult, error) {
+	return odm.CountItems(t, query)
+}
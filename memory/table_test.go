@@ -0,0 +1,722 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"git.devops.com/go/odm"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Book struct {
+	Author string `odm:"PK"`
+	Title  string `odm:"SK"`
+	Age    int64
+	// 自定义数据库字段
+	JSONInfo  string   `json:"json_info"`
+	DyTagInfo string   `json:"dyInfo" dynamodbav:"dy_info"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// GetTestTable 每次打开一个独立的内存数据库
+func GetTestTable(t *testing.T) odm.Table {
+	db, err := odm.Open("memory", "")
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	return db.Table(&Book{})
+}
+
+func TestTable_PutItem(t *testing.T) {
+	table := GetTestTable(t)
+	t.Run("PutItem", func(t *testing.T) {
+		err := table.PutItem(&Book{Author: "Tom", Title: "Hello", Age: 10}, nil, nil)
+		assert.NoError(t, err)
+	})
+	t.Run("Condition", func(t *testing.T) {
+		old := &Book{}
+		err := table.PutItem(&Book{Author: "Tom", Title: "Hello", Age: 11}, &odm.WriteOption{
+			Condition:   "Age = :age",
+			ValueParams: odm.Map{":age": 10},
+		}, old)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), old.Age)
+		err = table.PutItem(&Book{Author: "Tom", Title: "Hello", Age: 12}, &odm.WriteOption{
+			Condition: "attribute_not_exists(Author)",
+		}, nil)
+		aerr, ok := err.(awserr.Error)
+		assert.True(t, ok)
+		assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, aerr.Code())
+		assert.True(t, errors.Is(err, odm.ErrConditionFailed))
+	})
+	t.Run("Missing key", func(t *testing.T) {
+		err := table.PutItem(&Book{Author: "Tom"}, nil, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestTable_UpdateItem(t *testing.T) {
+	table := GetTestTable(t)
+	book := &Book{
+		Author: "Tom",
+		Title:  "2",
+		Age:    10,
+	}
+	err := table.PutItem(book, nil, nil)
+	assert.NoError(t, err)
+	t.Run("SET", func(t *testing.T) {
+		err = table.UpdateItem("Tom", "2", "SET json_info=:Info, Age = Age + :inc", &odm.WriteOption{
+			ValueParams: odm.Map{
+				":Info": "World",
+				":inc":  5,
+			},
+		}, book)
+		assert.NoError(t, err)
+		assert.Equal(t, &Book{
+			Author:   "Tom",
+			Title:    "2",
+			Age:      15,
+			JSONInfo: "World",
+		}, book)
+		book1 := &Book{}
+		err = table.GetItem("Tom", "2", nil, book1)
+		assert.NoError(t, err)
+		assert.Equal(t, book, book1)
+	})
+	t.Run("ADD, REMOVE and list_append", func(t *testing.T) {
+		err = table.UpdateItem("Tom", "2", "SET tags = if_not_exists(tags, :tags) ADD Age :inc REMOVE json_info", &odm.WriteOption{
+			ValueParams: odm.Map{
+				":tags": []string{"a"},
+				":inc":  -5,
+			},
+		}, nil)
+		assert.NoError(t, err)
+		err = table.UpdateItem("Tom", "2", "SET tags = list_append(tags, :tags)", &odm.WriteOption{
+			ValueParams: odm.Map{
+				":tags": []string{"b"},
+			},
+		}, nil)
+		assert.NoError(t, err)
+		book1 := &Book{}
+		err = table.GetItem("Tom", "2", nil, book1)
+		assert.NoError(t, err)
+		assert.Equal(t, &Book{
+			Author: "Tom",
+			Title:  "2",
+			Age:    10,
+			Tags:   []string{"a", "b"},
+		}, book1)
+	})
+	t.Run("Condition", func(t *testing.T) {
+		err = table.UpdateItem("Tom", "2", "SET Age = :age", &odm.WriteOption{
+			Condition:   "Age > :age",
+			ValueParams: odm.Map{":age": 100},
+		}, nil)
+		aerr, ok := err.(awserr.Error)
+		assert.True(t, ok)
+		assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, aerr.Code())
+	})
+	t.Run("Update key", func(t *testing.T) {
+		err = table.UpdateItem("Tom", "2", "SET Author = :a", &odm.WriteOption{
+			ValueParams: odm.Map{":a": "Jerry"},
+		}, nil)
+		assert.Error(t, err)
+	})
+	t.Run("Upsert", func(t *testing.T) {
+		err = table.UpdateItem("Tom", "new", "SET Age = :age", &odm.WriteOption{
+			ValueParams: odm.Map{":age": 1},
+		}, nil)
+		assert.NoError(t, err)
+		book1 := &Book{}
+		err = table.GetItem("Tom", "new", nil, book1)
+		assert.NoError(t, err)
+		assert.Equal(t, &Book{Author: "Tom", Title: "new", Age: 1}, book1)
+	})
+}
+
+type Member struct {
+	Id      string            `odm:"PK"`
+	Age     int               `json:",omitempty"`
+	Profile map[string]string `json:",omitempty"`
+}
+
+func TestTable_UpdateFields(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	table := db.Table(&Member{})
+	assert.NoError(t, table.PutItem(&Member{Id: "1", Age: 1, Profile: map[string]string{"Nick": "a", "City": "b"}}, nil, nil))
+
+	member := &Member{}
+	err := table.UpdateFields("1", nil, odm.Map{"Age": 2, "Profile.Nick": "x", "Profile.City": nil}, &odm.WriteOption{
+		Condition:   "Age = :f0",
+		ValueParams: odm.Map{":f0": 1},
+	}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, table.GetItem("1", nil, nil, member))
+	assert.Equal(t, &Member{Id: "1", Age: 2, Profile: map[string]string{"Nick": "x"}}, member)
+
+	// 条件不满足
+	err = table.UpdateFields("1", nil, odm.Map{"Age": 3}, &odm.WriteOption{
+		Condition:   "Age = :f0",
+		ValueParams: odm.Map{":f0": 1},
+	}, nil)
+	assert.Error(t, err)
+
+	assert.NoError(t, table.UpdateFields("1", nil, odm.Map{"Profile": nil}, nil, nil))
+	member = &Member{}
+	assert.NoError(t, table.GetItem("1", nil, nil, member))
+	assert.Equal(t, &Member{Id: "1", Age: 2}, member)
+}
+
+// Session 的主键在 json 中被忽略，但仍然按 odm 注解写入数据库
+type Session struct {
+	Token string `odm:"PK" json:"-"`
+	Data  string `json:"data,omitempty"`
+}
+
+func TestTable_FieldNames(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	table := db.Table(&Session{})
+	assert.NoError(t, table.PutItem(&Session{Token: "t"}, nil, nil))
+	session := &Session{}
+	assert.NoError(t, table.GetItem("t", nil, nil, session))
+	assert.Equal(t, &Session{Token: "t"}, session)
+
+	items := []odm.Map{}
+	assert.NoError(t, table.Scan(nil, nil, &items))
+	assert.Equal(t, []odm.Map{{"Token": "t"}}, items)
+}
+
+func TestTable_UpdateModel(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	table := db.Table(&Member{})
+	assert.NoError(t, table.PutItem(&Member{Id: "1", Age: 1, Profile: map[string]string{"Nick": "a"}}, nil, nil))
+
+	// 只更新非空字段
+	assert.NoError(t, table.UpdateModel(&Member{Id: "1", Age: 2}, nil, nil, nil))
+	member := &Member{}
+	assert.NoError(t, table.GetItem("1", nil, nil, member))
+	assert.Equal(t, &Member{Id: "1", Age: 2, Profile: map[string]string{"Nick": "a"}}, member)
+
+	// 更新列出的字段，空的 Profile 被删除
+	assert.NoError(t, table.UpdateModel(&Member{Id: "1", Age: 3}, []string{"Age", "Profile"}, nil, nil))
+	member = &Member{}
+	assert.NoError(t, table.GetItem("1", nil, nil, member))
+	assert.Equal(t, &Member{Id: "1", Age: 3}, member)
+
+	assert.Error(t, table.UpdateModel(&Member{Id: "1"}, nil, nil, nil))
+}
+
+func TestTable_GetItem(t *testing.T) {
+	table := GetTestTable(t)
+	book := &Book{
+		Author:    "Tom",
+		Title:     "Hello",
+		Age:       10,
+		JSONInfo:  "JSON",
+		DyTagInfo: "DyTag",
+	}
+	err := table.PutItem(book, nil, nil)
+	assert.NoError(t, err)
+	t.Run("GetItem", func(t *testing.T) {
+		book1 := &Book{}
+		err = table.GetItem("Tom", "Hello", nil, book1)
+		assert.NoError(t, err)
+		assert.Equal(t, book, book1)
+	})
+	t.Run("Projection", func(t *testing.T) {
+		book1 := &Book{}
+		err = table.GetItem("Tom", "Hello", &odm.GetOption{
+			Select:     "#a, dy_info",
+			NameParams: map[string]string{"#a": "Age"},
+		}, book1)
+		assert.NoError(t, err)
+		assert.Equal(t, &Book{Age: 10, DyTagInfo: "DyTag"}, book1)
+	})
+	t.Run("Not found", func(t *testing.T) {
+		book1 := &Book{}
+		err = table.GetItem("Tom", "None", nil, book1)
+		assert.True(t, errors.Is(err, odm.ErrNotFound))
+		assert.Equal(t, &Book{}, book1)
+		assert.Equal(t, odm.ErrNotFound, table.GetItem("Tom", "None", nil, nil))
+	})
+}
+
+func TestTable_ConcurrentReads(t *testing.T) {
+	table := GetTestTable(t)
+	book := &Book{Author: "Tom", Title: "Hello", Age: 10}
+	assert.NoError(t, table.PutItem(book, nil, nil))
+	// 只有表名的 Table 被多个 goroutine 共享，go test -race 检查数据竞争
+	byName := table.GetDB().GetDialectTable(&odm.TableMeta{TableName: "book"})
+	errs := make(chan error, 16)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			result := &Book{}
+			errs <- byName.GetItem("Tom", "Hello", nil, result)
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		assert.NoError(t, <-errs)
+	}
+}
+
+func TestTable_DeleteItem(t *testing.T) {
+	table := GetTestTable(t)
+	book := &Book{
+		Author: "Tom",
+		Title:  "3",
+		Age:    10,
+	}
+	err := table.PutItem(book, nil, nil)
+	assert.NoError(t, err)
+	old := &Book{}
+	err = table.DeleteItem("Tom", "3", nil, old)
+	assert.NoError(t, err)
+	assert.Equal(t, book, old)
+	book1 := &Book{}
+	err = table.GetItem("Tom", "3", nil, book1)
+	assert.Equal(t, odm.ErrNotFound, err)
+	assert.Equal(t, &Book{}, book1)
+	// 不需要旧数据时删除不存在的 item 不返回错误
+	assert.Equal(t, odm.ErrNotFound, table.DeleteItem("Tom", "3", nil, old))
+	assert.NoError(t, table.DeleteItem("Tom", "3", nil, nil))
+}
+
+func TestTable_Query(t *testing.T) {
+	table := GetTestTable(t)
+	allBooks := []Book{}
+	for i := 0; i < 10; i++ {
+		allBooks = append(allBooks, Book{
+			Author: "Jack",
+			Title:  "Book" + strconv.Itoa(i),
+			Age:    int64(i),
+		})
+		table.PutItem(&allBooks[i], nil, nil)
+	}
+	t.Run("ASC page", func(t *testing.T) {
+		books := []Book{}
+		offsetKey := make(odm.Map)
+		query := &odm.QueryOption{
+			KeyFilter: "Author = :Author and Title > :Title",
+			ValueParams: odm.Map{
+				":Author": "Jack",
+				":Title":  "Book",
+			},
+			Limit: 3,
+		}
+		err := table.Query(query, offsetKey, &books)
+		assert.NoError(t, err)
+		assert.Equal(t, allBooks[:3], books)
+		assert.Equal(t, odm.Map{"Author": "Jack", "Title": "Book2"}, offsetKey)
+		query.Limit = 5
+		err = table.Query(query, offsetKey, &books)
+		assert.NoError(t, err)
+		assert.Equal(t, allBooks[3:8], books)
+		err = table.Query(query, offsetKey, &books)
+		assert.NoError(t, err)
+		assert.Equal(t, allBooks[8:], books)
+		assert.Empty(t, offsetKey)
+	})
+	t.Run("DESC page", func(t *testing.T) {
+		books := []Book{}
+		offsetKey := make(odm.Map)
+		err := table.Query(&odm.QueryOption{
+			KeyFilter: "Author = :Author and Title BETWEEN :from AND :to",
+			ValueParams: odm.Map{
+				":Author": "Jack",
+				":from":   "Book2",
+				":to":     "Book5",
+			},
+			Desc:  true,
+			Limit: 2,
+		}, offsetKey, &books)
+		assert.NoError(t, err)
+		assert.Equal(t, []Book{allBooks[5], allBooks[4]}, books)
+		assert.Equal(t, odm.Map{"Author": "Jack", "Title": "Book4"}, offsetKey)
+	})
+	t.Run("Filter and Projection", func(t *testing.T) {
+		books := []Book{}
+		err := table.Query(&odm.QueryOption{
+			KeyFilter: "Author = :Author and begins_with(Title, :Title)",
+			ValueParams: odm.Map{
+				":Author": "Jack",
+				":Title":  "Book",
+				":Age":    5,
+			},
+			Filter: "Age=:Age",
+			Select: "Title, Age",
+		}, nil, &books)
+		assert.NoError(t, err)
+		assert.Equal(t, []Book{{Title: "Book5", Age: 5}}, books)
+	})
+	t.Run("Invalid key condition", func(t *testing.T) {
+		books := []Book{}
+		err := table.Query(&odm.QueryOption{
+			KeyFilter:   "Title = :Title",
+			ValueParams: odm.Map{":Title": "Book1"},
+		}, nil, &books)
+		assert.Error(t, err)
+	})
+	t.Run("Scan", func(t *testing.T) {
+		books := []Book{}
+		err := table.Query(&odm.QueryOption{
+			Filter:      "Age >= :Age",
+			ValueParams: odm.Map{":Age": 8},
+		}, nil, &books)
+		assert.NoError(t, err)
+		assert.Equal(t, allBooks[8:], books)
+	})
+}
+
+func TestTable_Scan(t *testing.T) {
+	table := GetTestTable(t)
+	allBooks := []Book{}
+	for i := 0; i < 20; i++ {
+		allBooks = append(allBooks, Book{
+			Author: "Author" + strconv.Itoa(i%5),
+			Title:  "Book" + strconv.Itoa(i),
+			Age:    int64(i),
+		})
+		table.PutItem(&allBooks[i], nil, nil)
+	}
+	t.Run("Page", func(t *testing.T) {
+		books := []Book{}
+		offsetKey := make(odm.Map)
+		count := 0
+		for {
+			err := table.Scan(&odm.ScanOption{QueryOption: odm.QueryOption{Limit: 3}}, offsetKey, &books)
+			assert.NoError(t, err)
+			count += len(books)
+			if len(offsetKey) == 0 {
+				break
+			}
+		}
+		assert.Equal(t, len(allBooks), count)
+	})
+	t.Run("Segments", func(t *testing.T) {
+		seen := map[string]int64{}
+		for segment := int64(0); segment < 3; segment++ {
+			books := []Book{}
+			err := table.Scan(&odm.ScanOption{Segment: segment, TotalSegments: 3}, nil, &books)
+			assert.NoError(t, err)
+			for _, book := range books {
+				_, ok := seen[book.Title]
+				assert.False(t, ok)
+				seen[book.Title] = segment
+			}
+		}
+		assert.Len(t, seen, len(allBooks))
+		err := table.Scan(&odm.ScanOption{Segment: 3, TotalSegments: 3}, nil, &[]Book{})
+		assert.Error(t, err)
+	})
+	t.Run("ParallelScan", func(t *testing.T) {
+		books := []Book{}
+		err := odm.ParallelScan(table, &odm.ScanOption{
+			QueryOption: odm.QueryOption{
+				Filter:      "#age >= :age",
+				Select:      "Title, #age",
+				NameParams:  map[string]string{"#age": "Age"},
+				ValueParams: odm.Map{":age": 10},
+			},
+		}, make([]odm.Map, 4), &books)
+		assert.NoError(t, err)
+		expect := []Book{}
+		for _, book := range allBooks[10:] {
+			expect = append(expect, Book{Title: book.Title, Age: book.Age})
+		}
+		assert.ElementsMatch(t, expect, books)
+	})
+	t.Run("ParallelScan page", func(t *testing.T) {
+		offsetKeys := make([]odm.Map, 3)
+		count := 0
+		for i := 0; i < 20; i++ {
+			books := []Book{}
+			err := odm.ParallelScan(table, &odm.ScanOption{QueryOption: odm.QueryOption{Limit: 2}}, offsetKeys, &books)
+			assert.NoError(t, err)
+			count += len(books)
+			done := true
+			for _, offsetKey := range offsetKeys {
+				done = done && len(offsetKey) == 0
+			}
+			if done {
+				break
+			}
+		}
+		assert.Equal(t, len(allBooks), count)
+	})
+	t.Run("ParallelScan by name", func(t *testing.T) {
+		// TableMeta 由各 Segment 并发初始化，go test -race 检查数据竞争
+		byName := table.GetDB().GetDialectTable(&odm.TableMeta{TableName: "book"})
+		books := []Book{}
+		err := odm.ParallelScan(byName, nil, make([]odm.Map, 8), &books)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, allBooks, books)
+	})
+}
+
+type Order struct {
+	Id        string `odm:"PK"`
+	CreatedAt int64  `odm:"SK"`
+	Email     string `odm:"gsi:by_email,PK" json:",omitempty"`
+	UpdatedAt int64  `odm:"lsi:by_time,SK,gsi:by_email,SK"`
+}
+
+func TestTable_Index(t *testing.T) {
+	db, _ := odm.Open("memory", "")
+	table := db.Table(&Order{})
+	orders := []Order{
+		{Id: "a", CreatedAt: 1, Email: "tom@a.com", UpdatedAt: 30},
+		{Id: "a", CreatedAt: 2, Email: "jack@a.com", UpdatedAt: 20},
+		{Id: "a", CreatedAt: 3, UpdatedAt: 10},
+		{Id: "b", CreatedAt: 1, Email: "tom@a.com", UpdatedAt: 20},
+	}
+	for i := range orders {
+		assert.NoError(t, table.PutItem(&orders[i], nil, nil))
+	}
+	meta, err := db.DialectDB.(*DB).GetTableMeta("order")
+	assert.NoError(t, err)
+	assert.NotNil(t, meta.GetIndex("by_email"))
+
+	t.Run("GSI", func(t *testing.T) {
+		results := []Order{}
+		offsetKey := odm.Map{}
+		query := &odm.QueryOption{
+			IndexName:   "by_email",
+			KeyFilter:   "Email = :email",
+			ValueParams: odm.Map{":email": "tom@a.com"},
+			Limit:       1,
+		}
+		assert.NoError(t, table.Query(query, offsetKey, &results))
+		assert.Equal(t, []Order{orders[3]}, results)
+		assert.Equal(t, odm.Map{"Id": "b", "CreatedAt": json.Number("1"), "Email": "tom@a.com", "UpdatedAt": json.Number("20")}, offsetKey)
+		assert.NoError(t, table.Query(query, offsetKey, &results))
+		assert.Equal(t, []Order{orders[0]}, results)
+	})
+	t.Run("LSI", func(t *testing.T) {
+		results := []Order{}
+		err := table.Query(&odm.QueryOption{
+			IndexName:   "by_time",
+			KeyFilter:   "Id = :id and UpdatedAt >= :t",
+			ValueParams: odm.Map{":id": "a", ":t": 20},
+		}, nil, &results)
+		assert.NoError(t, err)
+		assert.Equal(t, []Order{orders[1], orders[0]}, results)
+	})
+	t.Run("Sparse", func(t *testing.T) {
+		results := []Order{}
+		err := table.Scan(&odm.ScanOption{QueryOption: odm.QueryOption{IndexName: "by_email"}}, nil, &results)
+		assert.NoError(t, err)
+		assert.Equal(t, []Order{orders[1], orders[3], orders[0]}, results)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		results := []Order{}
+		err := table.Query(&odm.QueryOption{IndexName: "by_name", KeyFilter: "Id = :id", ValueParams: odm.Map{":id": "a"}}, nil, &results)
+		assert.Error(t, err)
+		assert.Equal(t, "ValidationException", err.(awserr.Error).Code())
+		assert.True(t, errors.Is(err, odm.ErrValidation))
+	})
+}
+
+func TestTable_QueryIter(t *testing.T) {
+	table := GetTestTable(t)
+	for i := 0; i < 10; i++ {
+		table.PutItem(&Book{Author: "Jack", Title: "Book" + strconv.Itoa(i), Age: int64(i)}, nil, nil)
+	}
+	query := &odm.QueryOption{
+		KeyFilter:   "Author = :Author",
+		Filter:      "Age >= :Age",
+		ValueParams: odm.Map{":Author": "Jack", ":Age": 5},
+	}
+	t.Run("Limit", func(t *testing.T) {
+		// 前 5 个 item 不满足 Filter，Query 的第一页为空，迭代器会继续翻页
+		q := *query
+		q.Limit = 3
+		page := []Book{}
+		assert.NoError(t, table.Query(&q, odm.Map{}, &page))
+		assert.Empty(t, page)
+
+		it := table.QueryIter(&q)
+		ages := []int64{}
+		book := Book{}
+		for it.Next(&book) {
+			ages = append(ages, book.Age)
+		}
+		assert.NoError(t, it.Err())
+		assert.Equal(t, []int64{5, 6, 7}, ages)
+		assert.Equal(t, odm.Map{"Author": "Jack", "Title": "Book7"}, it.LastKey())
+
+		// 从 LastKey 继续
+		it = table.QueryIter(&q).StartFrom(it.LastKey())
+		ages = []int64{}
+		for it.Next(&book) {
+			ages = append(ages, book.Age)
+		}
+		assert.NoError(t, it.Err())
+		assert.Equal(t, []int64{8, 9}, ages)
+		assert.Empty(t, it.LastKey())
+	})
+	t.Run("All", func(t *testing.T) {
+		it := table.ScanIter(&odm.ScanOption{QueryOption: odm.QueryOption{
+			Filter:      "Age < :Age",
+			ValueParams: odm.Map{":Age": 2},
+		}})
+		books := []*Book{}
+		book := &Book{}
+		for it.Next(&book) {
+			books = append(books, book)
+		}
+		assert.NoError(t, it.Err())
+		assert.Equal(t, []*Book{{Author: "Jack", Title: "Book0"}, {Author: "Jack", Title: "Book1", Age: 1}}, books)
+		assert.Empty(t, it.LastKey())
+	})
+	t.Run("Error", func(t *testing.T) {
+		it := table.QueryIter(&odm.QueryOption{KeyFilter: "Author = :Author"})
+		assert.False(t, it.Next(&Book{}))
+		assert.Error(t, it.Err())
+		it = table.QueryIter(nil)
+		assert.False(t, it.Next(&Book{}))
+		assert.Error(t, it.Err())
+	})
+}
+
+func TestTable_Count(t *testing.T) {
+	table := GetTestTable(t)
+	for i := 0; i < 10; i++ {
+		table.PutItem(&Book{Author: "Jack", Title: "Book" + strconv.Itoa(i), Age: int64(i)}, nil, nil)
+	}
+	table.PutItem(&Book{Author: "Tom", Title: "Hello"}, nil, nil)
+	t.Run("Query", func(t *testing.T) {
+		count, err := table.Count(&odm.QueryOption{
+			KeyFilter:   "Author = :Author",
+			Filter:      "Age >= :Age",
+			Select:      "Title",
+			ValueParams: odm.Map{":Author": "Jack", ":Age": 6},
+			Limit:       3,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, &odm.CountResult{Count: 4, ScannedCount: 10}, count)
+	})
+	t.Run("Scan", func(t *testing.T) {
+		count, err := table.Count(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, &odm.CountResult{Count: 11, ScannedCount: 11}, count)
+
+		// 单页计数
+		page := odm.CountResult{}
+		offsetKey := odm.Map{}
+		assert.NoError(t, table.Scan(&odm.ScanOption{QueryOption: odm.QueryOption{Limit: 4}}, offsetKey, &page))
+		assert.Equal(t, odm.CountResult{Count: 4, ScannedCount: 4}, page)
+		assert.NotEmpty(t, offsetKey)
+	})
+	t.Run("Error", func(t *testing.T) {
+		_, err := table.Count(&odm.QueryOption{KeyFilter: "Author = :Author"})
+		assert.Error(t, err)
+	})
+}
+
+func TestTable_Cursor(t *testing.T) {
+	table := GetTestTable(t)
+	for i := 0; i < 5; i++ {
+		table.PutItem(&Book{Author: "Jack", Title: "Book" + strconv.Itoa(i), Age: int64(i)}, nil, nil)
+	}
+	cursor := odm.NewCursor([]byte("secret"), time.Hour)
+	query := &odm.QueryOption{
+		KeyFilter:   "Author = :Author",
+		ValueParams: odm.Map{":Author": "Jack"},
+		Limit:       2,
+	}
+	titles := []string{}
+	token := ""
+	for {
+		books := []Book{}
+		next, err := cursor.Query(table, query, token, &books)
+		assert.NoError(t, err)
+		for _, book := range books {
+			titles = append(titles, book.Title)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, []string{"Book0", "Book1", "Book2", "Book3", "Book4"}, titles)
+
+	// 令牌不能用于其他查询
+	books := []Book{}
+	_, err := cursor.Scan(table, nil, token, &books)
+	assert.Equal(t, odm.ErrInvalidCursor, err)
+
+	token, err = cursor.Scan(table, &odm.ScanOption{QueryOption: odm.QueryOption{Limit: 3}}, "", &books)
+	assert.NoError(t, err)
+	assert.Len(t, books, 3)
+	_, err = cursor.Scan(table, &odm.ScanOption{QueryOption: odm.QueryOption{Limit: 3}}, token, &books)
+	assert.NoError(t, err)
+	assert.Len(t, books, 2)
+
+	t.Run("Large number", func(t *testing.T) {
+		// 超过 float64 精度的数字主键，翻页时不能丢失精度
+		db, _ := odm.Open("memory", "")
+		table := db.Table(&Order{})
+		for i := int64(3); i <= 5; i++ {
+			assert.NoError(t, table.PutItem(&Order{Id: "a", CreatedAt: 9007199254740990 + i}, nil, nil))
+		}
+		query := &odm.QueryOption{
+			KeyFilter:   "Id = :id",
+			ValueParams: odm.Map{":id": "a"},
+			Limit:       1,
+		}
+		created := []int64{}
+		token := ""
+		for i := 0; i < 5; i++ {
+			orders := []Order{}
+			next, err := cursor.Query(table, query, token, &orders)
+			assert.NoError(t, err)
+			for _, order := range orders {
+				created = append(created, order.CreatedAt)
+			}
+			if next == "" {
+				break
+			}
+			token = next
+		}
+		assert.Equal(t, []int64{9007199254740993, 9007199254740994, 9007199254740995}, created)
+	})
+}
+
+func ExampleTable_Query() {
+	db, err := odm.Open("memory", "")
+	if err != nil {
+		fmt.Printf("Can't open memory db. %s\n", err.Error())
+		return
+	}
+	table := db.Table(&Book{})
+	for i := 0; i < 10; i++ {
+		table.PutItem(&Book{
+			Author: "Alice",
+			Title:  "Book" + strconv.Itoa(i),
+			Age:    int64(i),
+		}, nil, nil)
+	}
+	offsetKey := make(odm.Map)
+	books := []Book{}
+	query := &odm.QueryOption{
+		KeyFilter: "Author = :Author and Title > :Title",
+		ValueParams: odm.Map{
+			":Author": "Alice",
+			":Title":  "Book2",
+		},
+		Limit: 1,
+	}
+	table.Query(query, offsetKey, &books)
+	fmt.Println(books[0].Title)
+	table.Query(query, offsetKey, &books)
+	fmt.Println(books[0].Title)
+	// Output:
+	// Book3
+	// Book4
+}
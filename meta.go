@@ -18,6 +18,27 @@ type TableMeta struct {
 	PK        *FieldDefine
 	SK        *FieldDefine
 	Fields    []*FieldDefine
+	// Indexes 二级索引，按字段定义的顺序排列
+	Indexes []*IndexMeta
+}
+
+// GetIndex 根据索引名返回索引定义，不存在时返回 nil
+func (m *TableMeta) GetIndex(indexName string) *IndexMeta {
+	for _, index := range m.Indexes {
+		if index.IndexName == indexName {
+			return index
+		}
+	}
+	return nil
+}
+
+// IndexMeta 二级索引的定义
+type IndexMeta struct {
+	IndexName string
+	// Global 为 true 表示 GSI，否则为 LSI。LSI 的 PK 与表的 PK 相同
+	Global bool
+	PK     *FieldDefine
+	SK     *FieldDefine
 }
 
 type FieldDefine struct {
@@ -36,6 +57,8 @@ type FieldDefine struct {
 	PK        bool
 	SK        bool
 	OmitEmpty bool
+	// EncodeOptions dynamodbav 注解中除 omitempty 以外的选项，如 stringset、numberset、binaryset、string
+	EncodeOptions []string
 }
 
 func (f *FieldDefine) GetDBFieldName(dbname string) string {
@@ -77,17 +100,10 @@ func GetModelMeta(model Model) *TableMeta {
 		// meta.Name = inflection.Plural(util.ToSnakeCase(t.Name()))
 		meta.TableName = util.ToSnakeCase(t.Name())
 	}
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		fd := getFieldDefine(&f)
-		if fd == nil {
-			continue
-		}
-		meta.Fields = append(meta.Fields, fd)
-		if fd.PK {
-			meta.PK = fd
-		} else if fd.SK {
-			meta.SK = fd
+	collectFields(t, meta)
+	for _, index := range meta.Indexes {
+		if !index.Global {
+			index.PK = meta.PK
 		}
 	}
 	sort.Slice(meta.Fields, func(i, j int) bool {
@@ -113,9 +129,131 @@ func GetModelMeta(model Model) *TableMeta {
 
 var typeOfBytes = reflect.TypeOf([]byte(nil))
 
+// collectFields 收集结构体的字段定义，匿名嵌入的结构体和结构体指针的字段会被展开，与 dynamodbattribute 保持一致
+func collectFields(t reflect.Type, meta *TableMeta) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" && f.Tag.Get("dynamodbav") == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectFields(ft, meta)
+				continue
+			}
+		}
+		fd := getFieldDefine(&f)
+		if fd == nil {
+			continue
+		}
+		meta.Fields = append(meta.Fields, fd)
+		if fd.PK {
+			meta.PK = fd
+		} else if fd.SK {
+			meta.SK = fd
+		}
+		_, _, keys := parseODMTag(f.Tag.Get("odm"))
+		for _, key := range keys {
+			index := meta.GetIndex(key.indexName)
+			if index == nil {
+				index = &IndexMeta{
+					IndexName: key.indexName,
+					Global:    key.global,
+				}
+				meta.Indexes = append(meta.Indexes, index)
+			}
+			if key.pk {
+				index.PK = fd
+			} else {
+				index.SK = fd
+			}
+		}
+	}
+}
+
+// FieldByName 返回结构体 v 中名为 name 的字段，包括嵌入结构体中的字段。
+// 经过为 nil 的嵌入结构体指针时返回无效的 reflect.Value，见 reflect.Value.IsValid
+func FieldByName(v reflect.Value, name string) reflect.Value {
+	sf, ok := v.Type().FieldByName(name)
+	if !ok {
+		return reflect.Value{}
+	}
+	for i, x := range sf.Index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// indexKey 字段在二级索引中的角色
+type indexKey struct {
+	indexName string
+	global    bool
+	pk        bool
+}
+
+// parseODMTag 解析 odm 注解，返回字段是否为表的 PK、SK，以及在二级索引中的角色。
+// 索引以 gsi:name 或 lsi:name 声明，其后的 PK、SK 属于该索引，省略时 GSI 默认为 PK，LSI 默认为 SK。
+// Example:
+//
+//	Email string `odm:"gsi:by_email,PK"`
+//	Time  int64  `odm:"lsi:by_time,SK,gsi:by_email,SK"`
+func parseODMTag(tag string) (pk bool, sk bool, keys []indexKey) {
+	var current *indexKey
+	explicit := false
+	flush := func() {
+		if current != nil {
+			if !explicit {
+				current.pk = current.global
+			}
+			keys = append(keys, *current)
+		}
+	}
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		isPK := part == "PK" || part == "hashkey"
+		isSK := part == "SK" || part == "rangekey"
+		switch {
+		case strings.HasPrefix(part, "gsi:") || strings.HasPrefix(part, "lsi:"):
+			flush()
+			current = &indexKey{
+				indexName: part[4:],
+				global:    part[:3] == "gsi",
+			}
+			explicit = false
+		case current != nil && (isPK || isSK):
+			// LSI 的 PK 总是表的 PK
+			current.pk = isPK && current.global
+			explicit = true
+		case isPK:
+			pk = true
+		case isSK:
+			sk = true
+		}
+	}
+	flush()
+	return pk, sk, keys
+}
+
+// getFieldDefine 返回字段定义，未导出或被忽略的字段返回 nil。
+// Type 只对可以作为主键的字段有值，其他类型（如 map、struct）的 Type 为空
 func getFieldDefine(f *reflect.StructField) *FieldDefine {
+	if f.PkgPath != "" {
+		// unexported
+		return nil
+	}
+	ft := f.Type
+	if ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
 	t := ""
-	switch f.Type.Kind() {
+	switch ft.Kind() {
 	case reflect.String:
 		t = "S"
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
@@ -123,30 +261,59 @@ func getFieldDefine(f *reflect.StructField) *FieldDefine {
 		reflect.Float32, reflect.Float64, reflect.Bool:
 		t = "N"
 	case reflect.Slice:
-		if f.Type == typeOfBytes {
+		if ft == typeOfBytes {
 			t = "B"
 		}
-	default:
-		// Not support field type. won't create field.
-		return nil
 	}
 	odmTags := strings.Split(f.Tag.Get("odm"), ",")
 	jsonTags := strings.Split(f.Tag.Get("json"), ",")
 	dyTags := strings.Split(f.Tag.Get("dynamodbav"), ",")
-	if odmTags[0] == "" && jsonTags[0] == "-" {
+	if odmTags[0] == "" && (jsonTags[0] == "-" || dyTags[0] == "-") {
 		return nil
 	}
+	if jsonTags[0] == "-" {
+		jsonTags[0] = ""
+	}
+	if dyTags[0] == "-" {
+		dyTags[0] = ""
+	}
+	pk, sk, _ := parseODMTag(f.Tag.Get("odm"))
 	// snakeName := util.ToSnakeCase(f.Name)
 	d := &FieldDefine{
 		ModelFieldName: f.Name,
 		Type:           t,
-		PK:             odmTags[0] == "PK" || odmTags[0] == "hashkey",
-		SK:             odmTags[0] == "SK" || odmTags[0] == "rangekey",
-		OmitEmpty:      len(jsonTags) > 1 && jsonTags[1] == "omitempty",
+		PK:             pk,
+		SK:             sk,
+		OmitEmpty:      hasTagOption(jsonTags, "omitempty") || hasTagOption(dyTags, "omitempty"),
 		SchemaFieldName: map[string]string{
 			"json":     util.StringsOr(jsonTags[0], f.Name),
 			"dynamodb": util.StringsOr(dyTags[0], jsonTags[0], f.Name),
 		},
 	}
+	for _, option := range dyTags[1:] {
+		if option != "" && option != "omitempty" {
+			d.EncodeOptions = append(d.EncodeOptions, option)
+		}
+	}
 	return d
 }
+
+func hasTagOption(tags []string, option string) bool {
+	for _, tag := range tags[1:] {
+		if tag == option {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmptyValue 判断字段值是否为空：零值，或长度为 0 的 string、slice、map
+func IsEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
@@ -1,6 +1,7 @@
 package odm
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -92,3 +93,98 @@ func TestGetModelMeta(t *testing.T) {
 		Type: "B",
 	}, meta.Fields[4])
 }
+
+type Order struct {
+	Id        string `odm:"PK"`
+	CreatedAt int64  `odm:"SK"`
+	Email     string `odm:"gsi:by_email,PK"`
+	Status    string `odm:"gsi:by_status"`
+	UpdatedAt int64  `odm:"lsi:by_time,SK,gsi:by_email,SK"`
+}
+
+func TestGetModelMeta_Indexes(t *testing.T) {
+	meta := GetModelMeta(&Order{})
+	assert.Len(t, meta.Indexes, 3)
+
+	byEmail := meta.GetIndex("by_email")
+	assert.True(t, byEmail.Global)
+	assert.Equal(t, "Email", byEmail.PK.ModelFieldName)
+	assert.Equal(t, "UpdatedAt", byEmail.SK.ModelFieldName)
+	assert.False(t, byEmail.PK.PK)
+
+	byStatus := meta.GetIndex("by_status")
+	assert.True(t, byStatus.Global)
+	assert.Equal(t, "Status", byStatus.PK.ModelFieldName)
+	assert.Nil(t, byStatus.SK)
+
+	byTime := meta.GetIndex("by_time")
+	assert.False(t, byTime.Global)
+	assert.Equal(t, meta.PK, byTime.PK)
+	assert.Equal(t, "UpdatedAt", byTime.SK.ModelFieldName)
+
+	assert.Nil(t, meta.GetIndex("not_exists"))
+	assert.Equal(t, "Id", meta.PK.ModelFieldName)
+	assert.Equal(t, "CreatedAt", meta.SK.ModelFieldName)
+}
+
+type Timestamps struct {
+	CreatedAt int64 `json:"created_at"`
+}
+
+type Profile struct {
+	Timestamps
+	Id       string            `odm:"PK" json:"id"`
+	Nick     *string           `dynamodbav:"nick,omitempty"`
+	Tags     map[string]string `json:"tags"`
+	Secret   string            `dynamodbav:"-"`
+	internal string
+}
+
+func TestGetModelMeta_AllFields(t *testing.T) {
+	meta := GetModelMeta(&Profile{})
+	names := []string{}
+	for _, f := range meta.Fields {
+		names = append(names, f.ModelFieldName)
+	}
+	assert.Equal(t, []string{"Id", "CreatedAt", "Nick", "Tags"}, names)
+	assert.Equal(t, "created_at", meta.Fields[1].GetDBFieldName("dynamodb"))
+	assert.Equal(t, "S", meta.Fields[2].Type)
+	assert.True(t, meta.Fields[2].OmitEmpty)
+	assert.Equal(t, "nick", meta.Fields[2].GetDBFieldName("dynamodb"))
+	assert.Equal(t, "", meta.Fields[3].Type)
+}
+
+type Owner struct {
+	OwnerName string `json:"owner_name"`
+}
+
+type Pet struct {
+	*Timestamps
+	*Owner
+	Id string `odm:"PK"`
+}
+
+func TestGetModelMeta_PointerEmbed(t *testing.T) {
+	meta := GetModelMeta(&Pet{})
+	names := []string{}
+	for _, f := range meta.Fields {
+		names = append(names, f.ModelFieldName)
+	}
+	assert.Equal(t, []string{"Id", "CreatedAt", "OwnerName"}, names)
+	assert.Equal(t, "owner_name", meta.Fields[2].GetDBFieldName("dynamodb"))
+
+	// 嵌入指针为 nil 时字段无效
+	pet := reflect.ValueOf(&Pet{Owner: &Owner{OwnerName: "Tom"}}).Elem()
+	assert.Equal(t, "Tom", FieldByName(pet, "OwnerName").Interface())
+	assert.False(t, FieldByName(pet, "CreatedAt").IsValid())
+	assert.False(t, FieldByName(pet, "Unknown").IsValid())
+}
+
+func TestIsEmptyValue(t *testing.T) {
+	for _, v := range []interface{}{0, "", []int{}, map[string]int{}, (*int)(nil), false, Profile{}} {
+		assert.True(t, IsEmptyValue(reflect.ValueOf(v)), "%#v", v)
+	}
+	for _, v := range []interface{}{1, "a", []int{0}, map[string]int{"a": 0}, new(int), true, Profile{Id: "1"}} {
+		assert.False(t, IsEmptyValue(reflect.ValueOf(v)), "%#v", v)
+	}
+}
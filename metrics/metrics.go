@@ -0,0 +1,170 @@
+// Package metrics 以 Prometheus 指标统计 ODM 操作的耗时和错误。
+//
+// 指标的标签为 dialect（Dialect.GetName）、table、operation（如 PutItem），
+// 错误数另有 code 标签。批量操作和事务涉及多个表时 table 为以逗号连接的表名。
+// Example:
+//
+//	collector := metrics.NewCollector()
+//	prometheus.MustRegister(collector)
+//	db.Use(collector.Interceptor())
+package metrics
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"git.devops.com/go/odm"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var labelNames = []string{"dialect", "table", "operation"}
+
+type config struct {
+	namespace string
+	buckets   []float64
+}
+
+// Option NewCollector 的选项
+type Option func(*config)
+
+// WithNamespace 指标名的前缀，默认为 odm
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithBuckets 耗时直方图的分桶，单位为秒，默认为 prometheus.DefBuckets
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+// Collector 实现 prometheus.Collector，通过 Interceptor 接入 odm.ODMDB
+type Collector struct {
+	duration                 *prometheus.HistogramVec
+	errors                   *prometheus.CounterVec
+	notFound                 *prometheus.CounterVec
+	throttles                *prometheus.CounterVec
+	conditionalCheckFailures *prometheus.CounterVec
+	unprocessedItems         *prometheus.CounterVec
+	retries                  *prometheus.CounterVec
+}
+
+// NewCollector 创建 Collector，需要注册到 prometheus.Registerer
+func NewCollector(opts ...Option) *Collector {
+	cfg := &config{namespace: "odm", buckets: prometheus.DefBuckets}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	counter := func(name string, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Name:      name,
+			Help:      help,
+		}, append(append([]string{}, labelNames...), labels...))
+	}
+	return &Collector{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of ODM operations.",
+			Buckets:   cfg.buckets,
+		}, labelNames),
+		errors:                   counter("operation_errors_total", "ODM operations that returned an error, by error code.", "code"),
+		notFound:                 counter("not_found_total", "GetItem and DeleteItem operations whose item does not exist."),
+		throttles:                counter("throttles_total", "ODM operations rejected by throttling."),
+		conditionalCheckFailures: counter("conditional_check_failures_total", "ODM operations whose condition expression failed."),
+		unprocessedItems:         counter("unprocessed_items_total", "Items left unprocessed by batch operations."),
+		retries:                  counter("retries_total", "Retries of ODM operations, including retries of unprocessed batch items."),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.duration, c.errors, c.notFound, c.throttles, c.conditionalCheckFailures, c.unprocessedItems, c.retries}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// Interceptor 返回记录指标的 odm.Interceptor
+func (c *Collector) Interceptor() odm.Interceptor {
+	return func(ctx context.Context, op *odm.Operation, next func(context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+		table := strings.Join(op.TableNames(), ",")
+		c.duration.WithLabelValues(op.Dialect, table, op.Kind).Observe(time.Since(start).Seconds())
+		if op.Retry > 0 {
+			c.retries.WithLabelValues(op.Dialect, table, op.Kind).Inc()
+		}
+		for name, n := range unprocessedItems(op.Unprocessed) {
+			c.unprocessedItems.WithLabelValues(op.Dialect, name, op.Kind).Add(float64(n))
+		}
+		if err == nil {
+			return nil
+		}
+		// item 不存在是正常的结果，不计入错误
+		if errors.Is(err, odm.ErrNotFound) {
+			c.notFound.WithLabelValues(op.Dialect, table, op.Kind).Inc()
+			return err
+		}
+		c.errors.WithLabelValues(op.Dialect, table, op.Kind, errorCode(err)).Inc()
+		// 事务取消原因中的限流和条件检查失败同样计数
+		if errors.Is(err, odm.ErrThrottled) {
+			c.throttles.WithLabelValues(op.Dialect, table, op.Kind).Inc()
+		}
+		if errors.Is(err, odm.ErrConditionFailed) {
+			c.conditionalCheckFailures.WithLabelValues(op.Dialect, table, op.Kind).Inc()
+		}
+		return err
+	}
+}
+
+// errorCode 返回 awserr.Error 等带有错误码的错误的 Code，没有错误码时为 Unknown
+func errorCode(err error) string {
+	var coder interface{ Code() string }
+	switch {
+	case errors.As(err, &coder):
+		return coder.Code()
+	case errors.Is(err, context.Canceled):
+		return "Canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "DeadlineExceeded"
+	}
+	return "Unknown"
+}
+
+// unprocessedItems 按表统计未处理的 item 数量
+func unprocessedItems(unprocessed interface{}) map[string]int {
+	counts := map[string]int{}
+	switch unprocessed := unprocessed.(type) {
+	case []*odm.BatchGet:
+		for _, get := range unprocessed {
+			counts[get.TableName] += len(get.Keys) + len(get.KeyPairs)
+		}
+	case []*odm.BatchWrite:
+		for _, write := range unprocessed {
+			n := len(write.DeleteKeys)
+			if items := reflect.Indirect(reflect.ValueOf(write.PutItems)); items.Kind() == reflect.Slice {
+				n += items.Len()
+			}
+			counts[write.TableName] += n
+		}
+	}
+	return counts
+}
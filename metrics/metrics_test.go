@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"git.devops.com/go/odm"
+	_ "git.devops.com/go/odm/memory"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Book struct {
+	Author string `odm:"PK"`
+	Title  string `odm:"SK"`
+}
+
+// throttledDB 第一次批量写入时一半的数据未处理，事务总是因限流被取消
+type throttledDB struct {
+	odm.DialectDB
+	calls int
+}
+
+func (db *throttledDB) WithContext(ctx context.Context) odm.DialectDB {
+	return db
+}
+
+func (db *throttledDB) BatchWriteItem(options []*odm.BatchWrite, unprocessedItems *[]*odm.BatchWrite) error {
+	db.calls++
+	if db.calls == 1 {
+		*unprocessedItems = append(*unprocessedItems, &odm.BatchWrite{
+			TableName:  "book",
+			PutItems:   []Book{{Author: "Tom", Title: "A"}},
+			DeleteKeys: []odm.Map{{"Author": "Tom", "Title": "B"}},
+		})
+	}
+	return nil
+}
+
+func (db *throttledDB) TransactWriteItems(writes []*odm.TransactWrite, opt *odm.TransactWriteOption) error {
+	return odm.NewTransactionCanceledError([]*odm.CancellationReason{
+		odm.NewCancellationReason("ThrottlingError", "Throughput exceeds the current capacity", nil, nil),
+	}, awserr.New("TransactionCanceledException", "Transaction cancelled", nil))
+}
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector()
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(collector))
+
+	db, err := odm.Open("memory", "")
+	assert.NoError(t, err)
+	db.Use(collector.Interceptor())
+	table, err := db.ResetTable(&Book{})
+	assert.NoError(t, err)
+	assert.NoError(t, table.PutItem(&Book{Author: "Tom", Title: "Hello"}, nil, nil))
+	err = table.PutItem(&Book{Author: "Tom", Title: "Hello"}, &odm.WriteOption{Condition: "attribute_not_exists(Author)"}, nil)
+	assert.Error(t, err)
+
+	histogram := &dto.Metric{}
+	assert.NoError(t, collector.duration.WithLabelValues("memory", "book", odm.OpPutItem).(prometheus.Histogram).Write(histogram))
+	assert.Equal(t, uint64(2), histogram.Histogram.GetSampleCount())
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.errors.WithLabelValues("memory", "book", odm.OpPutItem, "ConditionalCheckFailedException")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.conditionalCheckFailures.WithLabelValues("memory", "book", odm.OpPutItem)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(collector.throttles.WithLabelValues("memory", "book", odm.OpPutItem)))
+
+	// item 不存在不计入错误
+	errorSeries := testutil.CollectAndCount(collector.errors)
+	assert.Equal(t, odm.ErrNotFound, table.GetItem("Tom", "Nobody", nil, &Book{}))
+	assert.Equal(t, float64(1), testutil.ToFloat64(collector.notFound.WithLabelValues("memory", "book", odm.OpGetItem)))
+	assert.Equal(t, errorSeries, testutil.CollectAndCount(collector.errors))
+
+	t.Run("Batch", func(t *testing.T) {
+		fake := &throttledDB{}
+		db := (&odm.ODMDB{DialectDB: fake}).Use(collector.Interceptor())
+		err := db.BatchWriteItemWithRetry([]*odm.BatchWrite{{TableName: "book", PutItems: []Book{{}, {}}}}, time.Now().Add(time.Minute), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, fake.calls)
+		assert.Equal(t, float64(2), testutil.ToFloat64(collector.unprocessedItems.WithLabelValues("", "book", odm.OpBatchWriteItem)))
+		assert.Equal(t, float64(1), testutil.ToFloat64(collector.retries.WithLabelValues("", "book", odm.OpBatchWriteItem)))
+
+		err = db.Transact().Update("book", "Tom", "A", "SET Age = :a", nil, nil).Commit()
+		assert.Error(t, err)
+		assert.Equal(t, float64(1), testutil.ToFloat64(collector.throttles.WithLabelValues("", "book", odm.OpTransactWriteItems)))
+		assert.Equal(t, float64(1), testutil.ToFloat64(collector.errors.WithLabelValues("", "book", odm.OpTransactWriteItems, "TransactionCanceledException")))
+	})
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "ValidationException", errorCode(awserr.New("ValidationException", "", nil)))
+	assert.Equal(t, "Canceled", errorCode(context.Canceled))
+	assert.Equal(t, "Unknown", errorCode(assert.AnError))
+}
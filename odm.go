@@ -1,24 +1,11 @@
 package odm
 
-import (
-	"errors"
-	"flag"
-	"os"
-	"strings"
-)
+import "errors"
 
 var dialectMap map[string]Dialect
-var dropTableEnabled bool
 
 func init() {
 	dialectMap = make(map[string]Dialect)
-	if strings.HasSuffix(os.Args[0], ".test") || flag.Lookup("test.v") != nil {
-		dropTableEnabled = true
-	}
-}
-
-func IsDropTableEnabled() bool {
-	return dropTableEnabled
 }
 
 func RegisterDialect(dbtype string, opener Dialect) {
@@ -28,7 +15,12 @@ func RegisterDialect(dbtype string, opener Dialect) {
 func GetDialect(dbtype string) Dialect {
 	return dialectMap[dbtype]
 }
-func Open(dbtype string, connectString string) (*ODMDB, error) {
+
+// Option Open 的选项，如 WithRetryPolicy
+type Option func(db *ODMDB)
+
+// Open 打开 dbtype 方言的数据库，opts 在连接串的配置之后生效
+func Open(dbtype string, connectString string, opts ...Option) (*ODMDB, error) {
 	dialect := GetDialect(dbtype)
 	if dialect == nil {
 		return nil, errors.New("No DB dialect <" + dbtype + "> register. Try `import \"git.devops.com/go/odm/dynamodb\"`")
@@ -37,7 +29,17 @@ func Open(dbtype string, connectString string) (*ODMDB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ODMDB{
+	db := &ODMDB{
 		DialectDB: dialectDB,
-	}, nil
+		dialect:   dialect.GetName(),
+	}
+	if configurer, ok := dialectDB.(RetryConfigurer); ok {
+		if policy := configurer.RetryPolicy(); policy != nil {
+			db.setRetryPolicy(policy)
+		}
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db, nil
 }
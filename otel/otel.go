@@ -0,0 +1,172 @@
+// Package otel 为 ODM 的每个操作创建 OpenTelemetry span。
+//
+// span 的属性包含方言、表名、操作类型、索引、item 数量、消耗的容量和错误码，
+// span 的 context 会传给实际的请求，与调用方的链路串联。
+// Example:
+//
+//	db.Use(otel.NewInterceptor())
+package otel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"git.devops.com/go/odm"
+	gootel "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 创建 Tracer 使用的名称
+const instrumentationName = "git.devops.com/go/odm/otel"
+
+// span 的属性
+const (
+	AttrDialect          = attribute.Key("db.system")
+	AttrOperation        = attribute.Key("db.operation")
+	AttrTable            = attribute.Key("odm.table")
+	AttrTables           = attribute.Key("odm.tables")
+	AttrIndex            = attribute.Key("odm.index")
+	AttrItemCount        = attribute.Key("odm.item_count")
+	AttrScannedCount     = attribute.Key("odm.scanned_count")
+	AttrConsumedCapacity = attribute.Key("odm.consumed_capacity")
+	AttrErrorCode        = attribute.Key("odm.error_code")
+	// AttrFound GetItem、DeleteItem 的 item 不存在时为 false，span 的状态不是 Error
+	AttrFound = attribute.Key("odm.found")
+)
+
+type config struct {
+	provider trace.TracerProvider
+}
+
+// Option NewInterceptor 的选项
+type Option func(*config)
+
+// WithTracerProvider 指定 TracerProvider，默认使用全局的 TracerProvider
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = provider
+	}
+}
+
+// NewInterceptor 返回为每个操作创建 span 的 odm.Interceptor
+func NewInterceptor(opts ...Option) odm.Interceptor {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(ctx context.Context, op *odm.Operation, next func(context.Context) error) error {
+		provider := cfg.provider
+		if provider == nil {
+			provider = gootel.GetTracerProvider()
+		}
+		tracer := provider.Tracer(instrumentationName)
+		name := op.Kind
+		if op.TableName != "" {
+			name += " " + op.TableName
+		}
+		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(startAttributes(op)...))
+		defer span.End()
+
+		err := next(ctx)
+		span.SetAttributes(endAttributes(op)...)
+		if errors.Is(err, odm.ErrNotFound) {
+			span.SetAttributes(AttrFound.Bool(false))
+		} else if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			if code := errorCode(err); code != "" {
+				span.SetAttributes(AttrErrorCode.String(code))
+			}
+		}
+		return err
+	}
+}
+
+// startAttributes 执行前即可确定的属性
+func startAttributes(op *odm.Operation) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		AttrDialect.String(op.Dialect),
+		AttrOperation.String(op.Kind),
+	}
+	if op.TableName != "" {
+		attrs = append(attrs, AttrTable.String(op.TableName))
+	} else if tables := op.TableNames(); len(tables) > 0 {
+		attrs = append(attrs, AttrTables.StringSlice(tables))
+	}
+	var indexName string
+	switch opt := op.Option.(type) {
+	case *odm.QueryOption:
+		if opt != nil {
+			indexName = opt.IndexName
+		}
+	case *odm.ScanOption:
+		if opt != nil {
+			indexName = opt.IndexName
+		}
+	}
+	if indexName != "" {
+		attrs = append(attrs, AttrIndex.String(indexName))
+	}
+	return attrs
+}
+
+// endAttributes 执行后得到的属性
+func endAttributes(op *odm.Operation) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{}
+	if count, ok := op.Result.(*odm.CountResult); ok {
+		attrs = append(attrs, AttrItemCount.Int64(count.Count), AttrScannedCount.Int64(count.ScannedCount))
+	} else if n, ok := itemCount(op); ok {
+		attrs = append(attrs, AttrItemCount.Int(n))
+	}
+	if len(op.ConsumedCapacity) > 0 {
+		var units float64
+		for _, c := range op.ConsumedCapacity {
+			units += c.CapacityUnits
+		}
+		attrs = append(attrs, AttrConsumedCapacity.Float64(units))
+	}
+	return attrs
+}
+
+// itemCount Query、Scan 和批量读取得到的 item 数量
+func itemCount(op *odm.Operation) (int, bool) {
+	if op.Err != nil {
+		return 0, false
+	}
+	switch op.Kind {
+	case odm.OpQuery, odm.OpScan:
+		return sliceLen(op.Result)
+	case odm.OpBatchGetItem:
+		results, _ := op.Result.([]interface{})
+		total := 0
+		for _, result := range results {
+			n, _ := sliceLen(result)
+			total += n
+		}
+		return total, true
+	}
+	return 0, false
+}
+
+func sliceLen(results interface{}) (int, bool) {
+	v := reflect.ValueOf(results)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return 0, false
+	}
+	return v.Len(), true
+}
+
+// errorCode 返回 awserr.Error 等带有错误码的错误的 Code
+func errorCode(err error) string {
+	var coder interface{ Code() string }
+	if errors.As(err, &coder) {
+		return coder.Code()
+	}
+	return ""
+}
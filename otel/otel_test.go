@@ -0,0 +1,106 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"git.devops.com/go/odm"
+	_ "git.devops.com/go/odm/memory"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Book struct {
+	Author string `odm:"PK,gsi:TitleIndex,SK"`
+	Title  string `odm:"SK,gsi:TitleIndex,PK"`
+	Age    int64
+}
+
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestNewInterceptor(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	db, err := odm.Open("memory", "")
+	assert.NoError(t, err)
+	db.Use(NewInterceptor(WithTracerProvider(provider)))
+	table, err := db.ResetTable(&Book{})
+	assert.NoError(t, err)
+	exporter.Reset()
+
+	// 调用方的 span 是 ODM span 的父级
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	table = table.WithContext(ctx)
+	assert.NoError(t, table.PutItem(&Book{Author: "Tom", Title: "Hello"}, nil, nil))
+	assert.NoError(t, table.PutItem(&Book{Author: "Tom", Title: "World"}, nil, nil))
+	books := []Book{}
+	assert.NoError(t, table.Query(&odm.QueryOption{
+		IndexName:   "TitleIndex",
+		KeyFilter:   "Title = :t",
+		ValueParams: odm.Map{":t": "Hello"},
+	}, nil, &books))
+	_, err = table.Count(&odm.QueryOption{KeyFilter: "Author = :a", ValueParams: odm.Map{":a": "Tom"}})
+	assert.NoError(t, err)
+	err = table.PutItem(&Book{Author: "Tom", Title: "Hello"}, &odm.WriteOption{Condition: "attribute_not_exists(Author)"}, nil)
+	assert.Error(t, err)
+	err = table.GetItem("Tom", "Nobody", nil, &Book{})
+	assert.Equal(t, odm.ErrNotFound, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 7)
+	put := spans[0]
+	assert.Equal(t, "PutItem book", put.Name)
+	assert.Equal(t, trace.SpanKindClient, put.SpanKind)
+	assert.Equal(t, parent.SpanContext().SpanID(), put.Parent.SpanID())
+	assert.Equal(t, "memory", attrs(put)[AttrDialect].AsString())
+	assert.Equal(t, "PutItem", attrs(put)[AttrOperation].AsString())
+	assert.Equal(t, "book", attrs(put)[AttrTable].AsString())
+
+	query := attrs(spans[2])
+	assert.Equal(t, "TitleIndex", query[AttrIndex].AsString())
+	assert.Equal(t, int64(1), query[AttrItemCount].AsInt64())
+
+	count := attrs(spans[3])
+	assert.Equal(t, int64(2), count[AttrItemCount].AsInt64())
+	assert.Equal(t, int64(2), count[AttrScannedCount].AsInt64())
+
+	failed := spans[4]
+	assert.Equal(t, codes.Error, failed.Status.Code)
+	assert.Equal(t, "ConditionalCheckFailedException", attrs(failed)[AttrErrorCode].AsString())
+
+	// item 不存在不是错误
+	missed := spans[5]
+	assert.Equal(t, "GetItem book", missed.Name)
+	assert.NotEqual(t, codes.Error, missed.Status.Code)
+	assert.Empty(t, missed.Events)
+	assert.False(t, attrs(missed)[AttrFound].AsBool())
+	_, ok := attrs(missed)[AttrErrorCode]
+	assert.False(t, ok)
+}
+
+func TestEndAttributes(t *testing.T) {
+	op := &odm.Operation{
+		Kind:   odm.OpBatchGetItem,
+		Result: []interface{}{&[]Book{{}, {}}, &[]odm.Map{{}}},
+		ConsumedCapacity: []*odm.ConsumedCapacity{
+			{TableName: "book", Capacity: odm.Capacity{CapacityUnits: 1.5}},
+			{TableName: "account", Capacity: odm.Capacity{CapacityUnits: 0.5}},
+		},
+	}
+	assert.Equal(t, []attribute.KeyValue{
+		AttrItemCount.Int(3),
+		AttrConsumedCapacity.Float64(2),
+	}, endAttributes(op))
+}
@@ -0,0 +1,144 @@
+package odm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy 请求因限流、事务冲突等暂时性错误失败时的重试策略，对单条操作、批量操作和事务统一生效，
+// BatchWriteItemWithRetry、BatchGetItemWithRetry 也按它重试未处理的数据。
+// 每次重试都会重新经过 Interceptor，Operation.Retry 为重试的次数
+type RetryPolicy struct {
+	// MaxAttempts 最多执行的次数，包含第一次请求，小于等于 1 时不重试
+	MaxAttempts int
+	// BaseDelay、MaxDelay 指数退避，第 n 次重试前等待 min(MaxDelay, BaseDelay*2^n)
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter 等待时间随机减少的比例，取值 [0, 1]，为 1 时在 [0, delay] 中随机选取
+	Jitter float64
+	// Retryable 判断错误是否可以重试，为 nil 时使用 IsRetryable
+	Retryable func(err error) bool
+	// Operations 按操作类型覆盖的策略，如 Operations[OpTransactWriteItems]
+	Operations map[string]*RetryPolicy
+}
+
+// DefaultRetryPolicy 与 AWS SDK 对 DynamoDB 的默认重试相当，最多重试 10 次
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 11,
+	BaseDelay:   50 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Jitter:      1,
+}
+
+// RetryConfigurer 可选，由支持重试配置的方言的 DialectDB 实现
+type RetryConfigurer interface {
+	// RetryPolicy 返回连接串中配置的重试策略，没有配置时返回 nil
+	RetryPolicy() *RetryPolicy
+	// DisableBuiltinRetry 关闭方言自身的重试（如 AWS SDK 的重试），由 ODMDB 按 RetryPolicy 统一重试
+	DisableBuiltinRetry()
+}
+
+// IsRetryable 限流、事务冲突和服务暂时不可用的错误可以重试
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrThrottled) || errors.Is(err, ErrTransactionConflict) || errors.Is(err, ErrUnavailable)
+}
+
+// ForOperation 返回 kind 对应的策略，没有覆盖时为 p 本身
+func (p *RetryPolicy) ForOperation(kind string) *RetryPolicy {
+	if p == nil {
+		return nil
+	}
+	if policy, ok := p.Operations[kind]; ok {
+		return policy
+	}
+	return p
+}
+
+// ShouldRetry 第 attempt 次执行（从 0 开始）返回 err 后是否重试
+func (p *RetryPolicy) ShouldRetry(attempt int, err error) bool {
+	if p == nil || err == nil || attempt+1 >= p.MaxAttempts {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// Delay 返回第 attempt 次重试（从 0 开始）前的等待时间
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.MaxDelay
+	if attempt < 32 {
+		if exp := p.BaseDelay << uint(attempt); exp > 0 && (d <= 0 || exp < d) {
+			d = exp
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+	jitter := p.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	if n := int64(float64(d) * jitter); n > 0 {
+		d -= time.Duration(rand.Int63n(n + 1))
+	}
+	return d
+}
+
+// WithRetryPolicy 设置 ODMDB 的重试策略，优先于连接串中的配置，为 nil 时不重试，方言自身的重试也会关闭
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(db *ODMDB) {
+		db.setRetryPolicy(policy)
+	}
+}
+
+// setRetryPolicy 经由 hookDB 按 policy 重试，并关闭方言自身的重试，policy 为 nil 时完全不重试
+func (db *ODMDB) setRetryPolicy(policy *RetryPolicy) {
+	if policy == nil {
+		// 与没有配置区分，BatchWriteItemWithRetry 等也不再重试
+		policy = &RetryPolicy{MaxAttempts: 1}
+	}
+	hooked := db.hooked()
+	hooked.retry = policy
+	if configurer, ok := hooked.DialectDB.(RetryConfigurer); ok {
+		configurer.DisableBuiltinRetry()
+	}
+}
+
+type retryKey struct{}
+
+// withRetry 标记 ctx 中的请求为第 attempt 次重试，Interceptor 通过 Operation.Retry 读取
+func withRetry(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, retryKey{}, attempt)
+}
+
+func retryFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(retryKey{}).(int)
+	return attempt
+}
+
+// retryPolicy 返回 db 配置的重试策略，没有配置时为 nil
+func (db *ODMDB) retryPolicy() *RetryPolicy {
+	if hooked, ok := db.DialectDB.(*hookDB); ok {
+		return hooked.retry
+	}
+	return nil
+}
+
+// sleep 等待 d，ctx 取消时立即返回 ctx.Err()
+func sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
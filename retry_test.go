@@ -0,0 +1,190 @@
+package odm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// busyDB 依次返回 errs 中的错误，之后成功
+type busyDB struct {
+	DialectDB
+	errs     []error
+	calls    int
+	policy   *RetryPolicy
+	disabled bool
+}
+
+func (db *busyDB) next() error {
+	db.calls++
+	if db.calls <= len(db.errs) {
+		return db.errs[db.calls-1]
+	}
+	return nil
+}
+
+func (db *busyDB) WithContext(ctx context.Context) DialectDB {
+	return db
+}
+
+func (db *busyDB) GetDialectTable(meta *TableMeta) Table {
+	return &busyTable{db: db}
+}
+
+func (db *busyDB) TransactWriteItems(writes []*TransactWrite, opt *TransactWriteOption) error {
+	return db.next()
+}
+
+func (db *busyDB) BatchWriteItem(options []*BatchWrite, unprocessedItems *[]*BatchWrite) error {
+	err := db.next()
+	if err != nil {
+		*unprocessedItems = append(*unprocessedItems, options...)
+	}
+	return err
+}
+
+func (db *busyDB) RetryPolicy() *RetryPolicy {
+	return db.policy
+}
+
+func (db *busyDB) DisableBuiltinRetry() {
+	db.disabled = true
+}
+
+type busyTable struct {
+	Table
+	db *busyDB
+}
+
+func (t *busyTable) WithContext(ctx context.Context) Table {
+	return t
+}
+
+func (t *busyTable) GetItem(hashKey interface{}, rangeKey interface{}, opt *GetOption, result Model) error {
+	return t.db.next()
+}
+
+type busyDialect struct {
+	db *busyDB
+}
+
+func (d *busyDialect) Open(connectString string) (DialectDB, error) {
+	return d.db, nil
+}
+
+func (d *busyDialect) GetName() string {
+	return "busy"
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 100 * time.Millisecond, Jitter: 0.5}
+	throttled := NewError(ErrThrottled, "ThrottlingException", "", nil)
+	assert.True(t, p.ShouldRetry(0, throttled))
+	assert.True(t, p.ShouldRetry(1, throttled))
+	assert.False(t, p.ShouldRetry(2, throttled))
+	assert.False(t, p.ShouldRetry(0, nil))
+	assert.False(t, p.ShouldRetry(0, NewError(ErrConditionFailed, "ConditionalCheckFailedException", "", nil)))
+	assert.True(t, p.ShouldRetry(0, NewTransactionCanceledError([]*CancellationReason{NewCancellationReason("TransactionConflict", "", nil, nil)}, nil)))
+	assert.False(t, (*RetryPolicy)(nil).ShouldRetry(0, throttled))
+
+	for attempt := 0; attempt < 100; attempt++ {
+		d := p.Delay(attempt)
+		assert.True(t, d <= 100*time.Millisecond)
+		if attempt == 0 {
+			assert.True(t, d >= 5*time.Millisecond && d <= 10*time.Millisecond)
+		} else {
+			assert.True(t, d >= 10*time.Millisecond)
+		}
+	}
+	assert.Equal(t, 40*time.Millisecond, (&RetryPolicy{BaseDelay: 10 * time.Millisecond}).Delay(2))
+
+	transact := &RetryPolicy{MaxAttempts: 1}
+	p.Operations = map[string]*RetryPolicy{OpTransactWriteItems: transact}
+	assert.Equal(t, transact, p.ForOperation(OpTransactWriteItems))
+	assert.Equal(t, p, p.ForOperation(OpGetItem))
+}
+
+func TestODMDB_RetryPolicy(t *testing.T) {
+	throttled := NewError(ErrThrottled, "ThrottlingException", "", nil)
+	policy := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	t.Run("Table", func(t *testing.T) {
+		dialect := &busyDB{errs: []error{throttled, throttled}}
+		RegisterDialect("busy", &busyDialect{db: dialect})
+		db, err := Open("busy", "", WithRetryPolicy(policy))
+		assert.NoError(t, err)
+		assert.True(t, dialect.disabled)
+		retries := []int{}
+		db.Use(func(ctx context.Context, op *Operation, next func(context.Context) error) error {
+			retries = append(retries, op.Retry)
+			return next(ctx)
+		})
+		assert.NoError(t, db.Table(&Book{}).GetItem("Tom", "Hello", nil, nil))
+		assert.Equal(t, 3, dialect.calls)
+		assert.Equal(t, []int{0, 1, 2}, retries)
+
+		// 不可重试的错误立即返回
+		dialect.calls, dialect.errs = 0, []error{ErrValidation}
+		assert.Equal(t, ErrValidation, db.Table(&Book{}).GetItem("Tom", "Hello", nil, nil))
+		assert.Equal(t, 1, dialect.calls)
+	})
+
+	t.Run("Dialect policy", func(t *testing.T) {
+		dialect := &busyDB{errs: []error{throttled, throttled, throttled}, policy: policy}
+		RegisterDialect("busy", &busyDialect{db: dialect})
+		db, err := Open("busy", "")
+		assert.NoError(t, err)
+		assert.True(t, dialect.disabled)
+		err = db.Transact().Update("book", "Tom", "Hello", "SET Age = :a", nil, nil).Commit()
+		assert.True(t, errors.Is(err, ErrThrottled))
+		assert.Equal(t, 3, dialect.calls)
+
+		// 代码中的配置优先
+		dialect = &busyDB{errs: []error{throttled}, policy: policy}
+		RegisterDialect("busy", &busyDialect{db: dialect})
+		db, err = Open("busy", "", WithRetryPolicy(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, throttled, db.Transact().Update("book", "Tom", "Hello", "SET Age = :a", nil, nil).Commit())
+		assert.Equal(t, 1, dialect.calls)
+	})
+
+	t.Run("Nil policy", func(t *testing.T) {
+		// 方言没有配置重试策略时，nil 也关闭方言自身的重试
+		dialect := &busyDB{errs: []error{throttled}}
+		RegisterDialect("busy", &busyDialect{db: dialect})
+		db, err := Open("busy", "")
+		assert.NoError(t, err)
+		assert.False(t, dialect.disabled)
+		db, err = Open("busy", "", WithRetryPolicy(nil))
+		assert.NoError(t, err)
+		assert.True(t, dialect.disabled)
+		assert.Equal(t, throttled, db.Table(&Book{}).GetItem("Tom", "Hello", nil, nil))
+		assert.Equal(t, 1, dialect.calls)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		dialect := &busyDB{errs: []error{throttled}}
+		db := &ODMDB{DialectDB: dialect}
+		db.setRetryPolicy(policy)
+		unprocessed := []*BatchWrite{}
+		err := db.BatchWriteItem([]*BatchWrite{{TableName: "t", DeleteKeys: []Map{{"id": 1}}}}, &unprocessed)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, dialect.calls)
+		// 失败的那次未处理的数据不返回
+		assert.Empty(t, unprocessed)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		dialect := &busyDB{errs: []error{throttled, throttled}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		db := (&ODMDB{DialectDB: dialect}).WithContext(ctx)
+		db.setRetryPolicy(&RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second})
+		err := db.Transact().Update("book", "Tom", "Hello", "SET Age = :a", nil, nil).Commit()
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 1, dialect.calls)
+	})
+}
@@ -0,0 +1,90 @@
+package odm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// ParallelScan 并行扫描 len(offsetKeys) 个 Segment，并按 Segment 顺序合并结果到 results。
+//
+// offsetKeys[i] 是第 i 个 Segment 的起始位置，nil 表示从头开始，扫描后会被更新，
+// 为空（非 nil）表示该 Segment 已扫描完毕，不会再次扫描。
+// 每个 Segment 使用 table.WithContext 得到的副本，Table 本身不需要支持并发。
+// opt.Limit 为 0 时扫描每个 Segment 的全部数据；否则每个 Segment 只读取一页，
+// 再次调用 ParallelScan 可以继续扫描。
+// Example:
+//
+//	offsetKeys := make([]odm.Map, 4)
+//	items := []Item{}
+//	odm.ParallelScan(table, &odm.ScanOption{}, offsetKeys, &items)
+func ParallelScan(table Table, opt *ScanOption, offsetKeys []Map, results interface{}) error {
+	resultsVal := reflect.ValueOf(results)
+	if resultsVal.Kind() != reflect.Ptr || resultsVal.Elem().Kind() != reflect.Slice {
+		return errors.New("ParallelScan results should be a pointer to slice")
+	}
+	if len(offsetKeys) == 0 {
+		return errors.New("ParallelScan requires at least one segment")
+	}
+	if opt == nil {
+		opt = &ScanOption{}
+	}
+	ctx := tableContext(table)
+	sliceType := resultsVal.Elem().Type()
+	segments := make([]reflect.Value, len(offsetKeys))
+	errs := make([]error, len(offsetKeys))
+	var wg sync.WaitGroup
+	for i := range offsetKeys {
+		segments[i] = reflect.MakeSlice(sliceType, 0, 0)
+		if offsetKeys[i] != nil && len(offsetKeys[i]) == 0 {
+			continue
+		}
+		if offsetKeys[i] == nil {
+			offsetKeys[i] = Map{}
+		}
+		segmentOpt := *opt
+		segmentOpt.Segment = int64(i)
+		segmentOpt.TotalSegments = int64(len(offsetKeys))
+		wg.Add(1)
+		go func(i int, table Table, segmentOpt *ScanOption) {
+			defer wg.Done()
+			segments[i], errs[i] = scanSegment(table, segmentOpt, offsetKeys[i], sliceType)
+		}(i, table.WithContext(ctx), &segmentOpt)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	merged := reflect.MakeSlice(sliceType, 0, 0)
+	for _, segment := range segments {
+		merged = reflect.AppendSlice(merged, segment)
+	}
+	resultsVal.Elem().Set(merged)
+	return nil
+}
+
+// tableContext 返回 table 所属 DB 的 context，DB 没有提供时为 context.Background()
+func tableContext(table Table) context.Context {
+	if db, ok := table.GetDB().(interface{ Context() context.Context }); ok {
+		return db.Context()
+	}
+	return context.Background()
+}
+
+// scanSegment 扫描一个 Segment，Limit 为 0 时读取全部分页
+func scanSegment(table Table, opt *ScanOption, offsetKey Map, sliceType reflect.Type) (reflect.Value, error) {
+	all := reflect.MakeSlice(sliceType, 0, 0)
+	for {
+		page := reflect.New(sliceType)
+		if err := table.Scan(opt, offsetKey, page.Interface()); err != nil {
+			return all, err
+		}
+		all = reflect.AppendSlice(all, page.Elem())
+		if opt.Limit != 0 || len(offsetKey) == 0 {
+			return all, nil
+		}
+	}
+}
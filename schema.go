@@ -0,0 +1,59 @@
+package odm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSchemaNotAllowed SchemaPolicy 不允许创建或删除该表
+var ErrSchemaNotAllowed = errors.New("Not allowed by SchemaPolicy")
+
+// SchemaPolicy 允许的表结构操作，在 Open 时通过 WithSchemaPolicy 或连接串配置，由方言在 CreateTable、DropTable 时检查。
+// 为 nil 时不允许创建和删除表
+type SchemaPolicy struct {
+	AllowCreateTable bool
+	AllowDropTable   bool
+	// Tables 允许创建、删除的表名，为空时不限制
+	Tables []string
+	// TablePrefix 允许创建、删除的表名前缀，为空时不限制，如 test_
+	TablePrefix string
+}
+
+// CheckCreateTable 不允许创建 tableName 时返回包装 ErrSchemaNotAllowed 的错误
+func (p *SchemaPolicy) CheckCreateTable(tableName string) error {
+	if p == nil || !p.AllowCreateTable || !p.allowTable(tableName) {
+		return fmt.Errorf("CreateTable <%s>: %w", tableName, ErrSchemaNotAllowed)
+	}
+	return nil
+}
+
+// CheckDropTable 不允许删除 tableName 时返回包装 ErrSchemaNotAllowed 的错误
+func (p *SchemaPolicy) CheckDropTable(tableName string) error {
+	if p == nil || !p.AllowDropTable || !p.allowTable(tableName) {
+		return fmt.Errorf("DropTable <%s>: %w", tableName, ErrSchemaNotAllowed)
+	}
+	return nil
+}
+
+func (p *SchemaPolicy) allowTable(tableName string) bool {
+	if p.TablePrefix != "" && !strings.HasPrefix(tableName, p.TablePrefix) {
+		return false
+	}
+	if len(p.Tables) == 0 {
+		return true
+	}
+	for _, name := range p.Tables {
+		if name == tableName {
+			return true
+		}
+	}
+	return false
+}
+
+// WithSchemaPolicy 设置允许的表结构操作，优先于连接串和方言的默认配置
+func WithSchemaPolicy(policy *SchemaPolicy) Option {
+	return func(db *ODMDB) {
+		db.SetSchemaPolicy(policy)
+	}
+}
@@ -0,0 +1,26 @@
+package odm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaPolicy(t *testing.T) {
+	var p *SchemaPolicy
+	assert.True(t, errors.Is(p.CheckCreateTable("book"), ErrSchemaNotAllowed))
+	assert.True(t, errors.Is(p.CheckDropTable("book"), ErrSchemaNotAllowed))
+
+	p = &SchemaPolicy{AllowCreateTable: true}
+	assert.NoError(t, p.CheckCreateTable("book"))
+	assert.EqualError(t, p.CheckDropTable("book"), "DropTable <book>: Not allowed by SchemaPolicy")
+
+	p = &SchemaPolicy{AllowCreateTable: true, AllowDropTable: true, TablePrefix: "test_"}
+	assert.NoError(t, p.CheckDropTable("test_book"))
+	assert.True(t, errors.Is(p.CheckDropTable("book"), ErrSchemaNotAllowed))
+
+	p.Tables = []string{"test_book"}
+	assert.NoError(t, p.CheckCreateTable("test_book"))
+	assert.True(t, errors.Is(p.CheckCreateTable("test_account"), ErrSchemaNotAllowed))
+}
@@ -1,16 +1,24 @@
 package odm
 
+import "context"
+
 // Table 表的基本底层操作, 按照DynamoDB的操作进行对应抽象
 type Table interface {
 	// GetDB returns instanceof DB
 	GetDB() DialectDB
+	// WithContext 返回使用 ctx 发起请求的 Table
+	WithContext(ctx context.Context) Table
 	// put a item, will replace entire item.
 	PutItem(item Model, cond *WriteOption, result Model) error
 	// Update attributes. item will fill base on ReturnValues.
 	UpdateItem(hashKey interface{}, rangeKey interface{}, updateExpr string, opt *WriteOption, result Model) error
-	// get a item
+	// UpdateFields 根据 fields 生成 UpdateExpression，值为 nil 的字段会被删除，见 odm.UpdateFields
+	UpdateFields(hashKey interface{}, rangeKey interface{}, fields Map, opt *WriteOption, result Model) error
+	// UpdateModel 根据 model 的主键只更新部分字段，fields 为空时更新所有非空字段，见 codec.UpdateModel
+	UpdateModel(model Model, fields []string, opt *WriteOption, result Model) error
+	// get a item, item 不存在时返回 ErrNotFound，result 不会被修改
 	GetItem(hashKey interface{}, rangeKey interface{}, opt *GetOption, result Model) error
-	// returns deleted item
+	// returns deleted item, result 不为 nil 且 item 不存在时返回 ErrNotFound
 	DeleteItem(hashKey interface{}, rangeKey interface{}, opt *WriteOption, result Model) error
 	// Query and fill in items, StartKey will be replaced after query
 	// result is slice of Model
@@ -19,18 +27,31 @@ type Table interface {
 	// 		items := []Item{}
 	// 		table.Query(query, offsetKey, &items)
 	Query(query *QueryOption, offsetKey Map, results interface{}) error
+	// Scan 扫描全表，offsetKey 的用法与 Query 相同
+	// 指定 Segment、TotalSegments 时只扫描其中一段，多段并行扫描见 ParallelScan
+	Scan(opt *ScanOption, offsetKey Map, results interface{}) error
+	// QueryIter 返回逐个读取 Query 结果的迭代器，自动翻页直到读取到 Limit 个 item，见 Iterator
+	QueryIter(query *QueryOption) *Iterator
+	// ScanIter 返回逐个读取 Scan 结果的迭代器，见 Iterator
+	ScanIter(opt *ScanOption) *Iterator
+	// Count 以 Select COUNT 统计满足 query 的 item 数量，自动翻页，KeyFilter 为空时执行 Scan，见 CountItems
+	Count(query *QueryOption) (*CountResult, error)
 }
 
 type WriteOption struct {
 	Condition   string
 	NameParams  map[string]string
 	ValueParams Map
+	// ReturnConsumedCapacity 见 ConsumedCapacityIndexes，事务中使用 TransactWriteOption 的设置
+	ReturnConsumedCapacity string
 }
 
 type GetOption struct {
 	Consistent bool
 	Select     string
 	NameParams map[string]string
+	// ReturnConsumedCapacity 见 ConsumedCapacityIndexes
+	ReturnConsumedCapacity string
 }
 
 type QueryOption struct {
@@ -55,7 +76,10 @@ type QueryOption struct {
 	// ExclusiveStartKey 由 StartKey 参数提供
 	// ExclusiveStartKey      Map               `type:"map"`
 
-	// QueryInput.Select 并没有什么用，只是关于信息范围的。无需指定
+	// ReturnConsumedCapacity 见 ConsumedCapacityIndexes
+	ReturnConsumedCapacity string
+
+	// QueryInput.Select 由 results 的类型决定：results 为 *CountResult 时为 COUNT，见 Table.Count
 	// Select string `type:"string" enum:"Select"`
 }
 
@@ -1,6 +1,7 @@
 package odm
 
 import (
+	"context"
 	"errors"
 )
 
@@ -44,6 +45,18 @@ func (t *transaction) makeWrite() *writeTransaction {
 	return t.writeTransaction
 }
 
+// WithContext 使事务使用 ctx 提交
+func (t *transaction) WithContext(ctx context.Context) *transaction {
+	t.db = t.db.WithContext(ctx)
+	if t.getTransaction != nil {
+		t.getTransaction.db = t.db
+	}
+	if t.writeTransaction != nil {
+		t.writeTransaction.db = t.db
+	}
+	return t
+}
+
 func (t *transaction) Commit() error {
 	if t.getTransaction != nil {
 		return t.getTransaction.Commit()
@@ -54,14 +67,14 @@ func (t *transaction) Commit() error {
 	return errors.New("Nothing to commit")
 }
 
-func (t *transaction) Get(args ...interface{}) *getTransaction {
-	return t.makeGet().Get(args...)
+func (t *transaction) Get(result Model, hashKey interface{}, args ...interface{}) *getTransaction {
+	return t.makeGet().Get(result, hashKey, args...)
 }
-func (t *transaction) Check(cond *WriteOption) *writeTransaction {
-	return t.makeWrite().Check(cond)
+func (t *transaction) Check(tableName string, hashKey interface{}, rangeKey interface{}, cond *WriteOption, failValue Model) *writeTransaction {
+	return t.makeWrite().Check(tableName, hashKey, rangeKey, cond, failValue)
 }
-func (t *transaction) Put(item Model, cond *WriteOption, failResult Model) *writeTransaction {
-	return t.makeWrite().Put(item, cond, failResult)
+func (t *transaction) Put(item Model, cond *WriteOption, failValue Model) *writeTransaction {
+	return t.makeWrite().Put(item, cond, failValue)
 }
 func (t *transaction) Update(tableName string, hashKey interface{}, rangeKey interface{}, updateExpr string, opt *WriteOption, failValue Model) *writeTransaction {
 	return t.makeWrite().Update(tableName, hashKey, rangeKey, updateExpr, opt, failValue)
@@ -69,64 +82,172 @@ func (t *transaction) Update(tableName string, hashKey interface{}, rangeKey int
 func (t *transaction) Delete(tableName string, hashKey interface{}, rangeKey interface{}, opt *WriteOption, failValue Model) *writeTransaction {
 	return t.makeWrite().Delete(tableName, hashKey, rangeKey, opt, failValue)
 }
+func (t *transaction) ClientRequestToken(token string) *writeTransaction {
+	return t.makeWrite().ClientRequestToken(token)
+}
 
 type writeTransaction struct {
 	db         *ODMDB
 	operations []*TransactWrite
+	// 与 operations 一一对应，事务因条件检查失败取消时填充旧数据
+	failValues []Model
+	option     *TransactWriteOption
+}
+
+func (t *writeTransaction) add(op *TransactWrite, failValue Model) *writeTransaction {
+	t.operations = append(t.operations, op)
+	t.failValues = append(t.failValues, failValue)
+	return t
+}
+
+// returnValues 仅当需要 failValue 时才返回条件检查失败时的旧数据
+func returnValues(failValue Model) string {
+	if failValue != nil {
+		return "ALL_OLD"
+	}
+	return ""
 }
 
-func (t *writeTransaction) Check(cond *WriteOption) *writeTransaction {
-	panic("not implemented")
-	return nil
+// Check 检查 item 是否满足条件，不做修改
+func (t *writeTransaction) Check(tableName string, hashKey interface{}, rangeKey interface{}, cond *WriteOption, failValue Model) *writeTransaction {
+	check := &ConditionCheck{
+		TableName:                           tableName,
+		HashKey:                             hashKey,
+		RangeKey:                            rangeKey,
+		ReturnValuesOnConditionCheckFailure: returnValues(failValue),
+	}
+	if cond != nil {
+		check.Condition = cond.Condition
+		check.NameParams = cond.NameParams
+		check.ValueParams = cond.ValueParams
+	}
+	return t.add(&TransactWrite{
+		ConditionCheck: check,
+	}, failValue)
 }
 
-func (t *writeTransaction) Put(item interface{}, cond *WriteOption, failValue interface{}) *writeTransaction {
+func (t *writeTransaction) Put(item Model, cond *WriteOption, failValue Model) *writeTransaction {
 	meta := GetModelMeta(item)
-	t.operations = append(t.operations, &TransactWrite{
+	return t.add(&TransactWrite{
 		Put: &Put{
-			TableName: meta.TableName,
-			Item:      item,
+			TableName:                           meta.TableName,
+			Item:                                item,
+			WriteOption:                         cond,
+			ReturnValuesOnConditionCheckFailure: returnValues(failValue),
 		},
-	})
-	panic("not implemented")
-	return t
+	}, failValue)
 }
 func (t *writeTransaction) Update(tableName string, hashKey interface{}, rangeKey interface{}, updateExpr string, opt *WriteOption, failValue Model) *writeTransaction {
-	op := &TransactWrite{
+	return t.add(&TransactWrite{
 		Update: &Update{
-			TableName:   tableName,
-			HashKey:     hashKey,
-			RangeKey:    rangeKey,
-			Expression:  updateExpr,
-			WriteOption: opt,
+			TableName:                           tableName,
+			HashKey:                             hashKey,
+			RangeKey:                            rangeKey,
+			Expression:                          updateExpr,
+			WriteOption:                         opt,
+			ReturnValuesOnConditionCheckFailure: returnValues(failValue),
 		},
-	}
-	if failValue != nil {
-		op.Update.ReturnValuesOnConditionCheckFailure = "ALL_NEW"
-	}
-	t.operations = append(t.operations, op)
+	}, failValue)
+}
+func (t *writeTransaction) Delete(tableName string, hashKey interface{}, rangeKey interface{}, opt *WriteOption, failValue Model) *writeTransaction {
+	return t.add(&TransactWrite{
+		Delete: &Delete{
+			TableName:                           tableName,
+			HashKey:                             hashKey,
+			RangeKey:                            rangeKey,
+			WriteOption:                         opt,
+			ReturnValuesOnConditionCheckFailure: returnValues(failValue),
+		},
+	}, failValue)
+}
+
+// WithContext 使事务使用 ctx 提交
+func (t *writeTransaction) WithContext(ctx context.Context) *writeTransaction {
+	t.db = t.db.WithContext(ctx)
 	return t
 }
-func (t *writeTransaction) Delete(args ...interface{}) *writeTransaction {
-	t.operations = append(t.operations)
-	panic("not implemented")
+
+// ClientRequestToken 设置幂等标识，避免网络重试导致事务重复执行
+func (t *writeTransaction) ClientRequestToken(token string) *writeTransaction {
+	t.option = &TransactWriteOption{
+		ClientRequestToken: token,
+	}
 	return t
 }
+
+// Commit 提交事务。事务被取消时返回 *TransactionCanceledError，并将旧数据填充到对应的 failValue
 func (t *writeTransaction) Commit() error {
-	return t.db.TransactWriteItems(t.operations)
+	err := t.db.TransactWriteItems(t.operations, t.option)
+	var canceled *TransactionCanceledError
+	if !errors.As(err, &canceled) {
+		return err
+	}
+	for i, reason := range canceled.Reasons {
+		if i >= len(t.failValues) || t.failValues[i] == nil {
+			continue
+		}
+		if uerr := reason.Unmarshal(t.failValues[i]); uerr != nil {
+			return uerr
+		}
+	}
+	return err
 }
 
 type getTransaction struct {
 	db         *ODMDB
 	operations []*TransactGet
+	results    []Model
+	found      []bool
+}
+
+// Get 读取 result 对应表中的一个 item，表名和主键定义由 GetModelMeta(result) 得到。
+// args 依次为可选的 rangeKey 和 *GetOption
+// Example:
+//
+//	db.Transact().Get(&account, 1).Get(&bag, 1, "sku").Commit()
+func (t *getTransaction) Get(result Model, hashKey interface{}, args ...interface{}) *getTransaction {
+	meta := GetModelMeta(result)
+	get := &TransactGet{
+		TableName: meta.TableName,
+		Meta:      meta,
+		HashKey:   hashKey,
+	}
+	for _, arg := range args {
+		if opt, ok := arg.(*GetOption); ok {
+			if opt != nil {
+				get.Select = opt.Select
+				get.NameParams = opt.NameParams
+			}
+			continue
+		}
+		get.RangeKey = arg
+	}
+	t.operations = append(t.operations, get)
+	t.results = append(t.results, result)
+	return t
 }
 
-func (t *getTransaction) Get(args ...interface{}) *getTransaction {
-	panic("not implemented")
+// WithContext 使事务使用 ctx 提交
+func (t *getTransaction) WithContext(ctx context.Context) *getTransaction {
+	t.db = t.db.WithContext(ctx)
 	return t
 }
 
+// Commit 一致性读取全部 item，按顺序填充到 result。未找到的 item 保持原样，可以用 Found 判断
 func (t *getTransaction) Commit() error {
-	panic("not implemented")
-	return nil
+	t.found = make([]bool, len(t.operations))
+	for i, get := range t.operations {
+		get.Found = &t.found[i]
+	}
+	return t.db.TransactGetItems(t.operations, t.results...)
+}
+
+// Found 判断 Commit 后 result 对应的 item 是否存在
+func (t *getTransaction) Found(result Model) bool {
+	for i, r := range t.results {
+		if r == result && i < len(t.found) {
+			return t.found[i]
+		}
+	}
+	return false
 }
@@ -0,0 +1,189 @@
+package odm
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UpdateFields 根据 fields 生成 UpdateExpression 并执行 UpdateItem，供方言实现 Table.UpdateFields。
+// 值为 nil 的字段被 REMOVE，其余字段被 SET，字段名可以是 a.b、a[0] 形式的嵌套路径。
+// 生成的占位符不会与 opt 中已有的 NameParams、ValueParams 冲突，opt.Condition 原样保留。
+// Example:
+//
+//	table.UpdateFields("Tom", "Hello", odm.Map{"Age": 3, "Profile.Nick": "x", "Deleted": nil}, nil, &book)
+func UpdateFields(table Table, hashKey interface{}, rangeKey interface{}, fields Map, opt *WriteOption, result Model) error {
+	updateExpr, merged, err := FieldsToUpdate(fields, opt)
+	if err != nil {
+		return err
+	}
+	return table.UpdateItem(hashKey, rangeKey, updateExpr, merged, result)
+}
+
+// ModelKey 返回 model 的 PK、SK 的值，没有 SK 时 rangeKey 为 nil
+func ModelKey(model Model) (hashKey interface{}, rangeKey interface{}, err error) {
+	meta := GetModelMeta(model)
+	if meta.PK == nil {
+		return nil, nil, errors.New("PK is not defined in " + meta.TableName)
+	}
+	v := reflect.Indirect(reflect.ValueOf(model))
+	fv := FieldByName(v, meta.PK.ModelFieldName)
+	if !fv.IsValid() {
+		return nil, nil, errors.New("PK of " + meta.TableName + " is in a nil embedded struct")
+	}
+	hashKey = fv.Interface()
+	if meta.SK != nil {
+		fv = FieldByName(v, meta.SK.ModelFieldName)
+		if !fv.IsValid() {
+			return nil, nil, errors.New("SK of " + meta.TableName + " is in a nil embedded struct")
+		}
+		rangeKey = fv.Interface()
+	}
+	return hashKey, rangeKey, nil
+}
+
+// ModelFields 返回 model 中需要更新的字段，key 为 schema 中的字段名，不包含主键。
+// fields 为空时返回所有非空的字段；否则只返回 fields 列出的字段（结构体字段名或 schema 中的字段名），
+// 其中为 nil 的指针、map、slice 的值为 nil，UpdateFields 会将其删除
+func ModelFields(model Model, schema string, fields ...string) (Map, error) {
+	meta := GetModelMeta(model)
+	v := reflect.Indirect(reflect.ValueOf(model))
+	listed := map[string]bool{}
+	for _, name := range fields {
+		listed[name] = true
+	}
+	values := Map{}
+	for _, f := range meta.Fields {
+		if f.PK || f.SK {
+			continue
+		}
+		name := f.GetDBFieldName(schema)
+		fv := FieldByName(v, f.ModelFieldName)
+		if len(fields) > 0 {
+			if !listed[f.ModelFieldName] && !listed[name] {
+				continue
+			}
+			delete(listed, f.ModelFieldName)
+			delete(listed, name)
+		} else if IsEmptyValue(fv) {
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.Invalid:
+			// 所在的嵌入结构体指针为 nil
+			values[name] = nil
+			continue
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			if fv.IsNil() {
+				values[name] = nil
+				continue
+			}
+		}
+		values[name] = fv.Interface()
+	}
+	for name := range listed {
+		return nil, errors.New("Field " + name + " is not found or is part of the key")
+	}
+	return values, nil
+}
+
+// FieldsToUpdate 将 fields 转换为 UpdateExpression，并返回合并了占位符的 WriteOption，opt 不会被修改。
+// 互相重叠的路径（如 Profile 与 Profile.Nick）会返回错误
+func FieldsToUpdate(fields Map, opt *WriteOption) (string, *WriteOption, error) {
+	if len(fields) == 0 {
+		return "", nil, errors.New("UpdateFields requires at least one field")
+	}
+	merged := &WriteOption{
+		NameParams:  map[string]string{},
+		ValueParams: Map{},
+	}
+	if opt != nil {
+		merged.Condition = opt.Condition
+		merged.ReturnConsumedCapacity = opt.ReturnConsumedCapacity
+		for k, v := range opt.NameParams {
+			merged.NameParams[k] = v
+		}
+		for k, v := range opt.ValueParams {
+			merged.ValueParams[k] = v
+		}
+	}
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	// 排序后，以 p 为前缀的路径（p.x、p[0]）排在 p 之后
+	for i := 1; i < len(paths); i++ {
+		for j := i - 1; j >= 0; j-- {
+			p, q := paths[j], paths[i]
+			if strings.HasPrefix(q, p) && (q[len(p)] == '.' || q[len(p)] == '[') {
+				return "", nil, errors.New("Overlapping field paths: " + p + " and " + q)
+			}
+		}
+	}
+
+	// 跳过 opt 中已经使用的占位符
+	nameIndex, valueIndex := 0, 0
+	newValue := func(v interface{}) string {
+		for {
+			p := ":f" + strconv.Itoa(valueIndex)
+			valueIndex++
+			if _, ok := merged.ValueParams[p]; !ok {
+				merged.ValueParams[p] = v
+				return p
+			}
+		}
+	}
+	names := map[string]string{}
+	nameOf := func(attr string) string {
+		if p, ok := names[attr]; ok {
+			return p
+		}
+		for {
+			p := "#f" + strconv.Itoa(nameIndex)
+			nameIndex++
+			if _, ok := merged.NameParams[p]; !ok {
+				names[attr] = p
+				merged.NameParams[p] = attr
+				return p
+			}
+		}
+	}
+
+	sets := []string{}
+	removes := []string{}
+	for _, path := range paths {
+		elems := strings.Split(path, ".")
+		for i, elem := range elems {
+			attr, index := elem, ""
+			if j := strings.IndexByte(elem, '['); j >= 0 {
+				attr, index = elem[:j], elem[j:]
+			}
+			if attr == "" {
+				return "", nil, errors.New("Invalid field path: " + path)
+			}
+			elems[i] = nameOf(attr) + index
+		}
+		name := strings.Join(elems, ".")
+		v := fields[path]
+		if v == nil {
+			removes = append(removes, name)
+			continue
+		}
+		sets = append(sets, name+" = "+newValue(v))
+	}
+
+	clauses := []string{}
+	if len(sets) > 0 {
+		clauses = append(clauses, "SET "+strings.Join(sets, ", "))
+	}
+	if len(removes) > 0 {
+		clauses = append(clauses, "REMOVE "+strings.Join(removes, ", "))
+	}
+	if len(merged.ValueParams) == 0 {
+		merged.ValueParams = nil
+	}
+	return strings.Join(clauses, " "), merged, nil
+}
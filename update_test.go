@@ -0,0 +1,79 @@
+package odm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldsToUpdate(t *testing.T) {
+	expr, opt, err := FieldsToUpdate(Map{
+		"Age":          3,
+		"Profile.Nick": "x",
+		"Profile.Tags": nil,
+		"Logs[0]":      "first",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #f0 = :f0, #f1[0] = :f1, #f2.#f3 = :f2 REMOVE #f2.#f4", expr)
+	assert.Equal(t, map[string]string{"#f0": "Age", "#f1": "Logs", "#f2": "Profile", "#f3": "Nick", "#f4": "Tags"}, opt.NameParams)
+	assert.Equal(t, Map{":f0": 3, ":f1": "first", ":f2": "x"}, opt.ValueParams)
+
+	// 与已有的条件合并，占位符不冲突，且不修改原来的 opt
+	cond := &WriteOption{
+		Condition:   "#f0 = :f0",
+		NameParams:  map[string]string{"#f0": "Status"},
+		ValueParams: Map{":f0": 1},
+	}
+	expr, opt, err = FieldsToUpdate(Map{"Status": 2}, cond)
+	assert.NoError(t, err)
+	assert.Equal(t, "SET #f1 = :f1", expr)
+	assert.Equal(t, "#f0 = :f0", opt.Condition)
+	assert.Equal(t, map[string]string{"#f0": "Status", "#f1": "Status"}, opt.NameParams)
+	assert.Equal(t, Map{":f0": 1, ":f1": 2}, opt.ValueParams)
+	assert.Equal(t, map[string]string{"#f0": "Status"}, cond.NameParams)
+
+	expr, opt, err = FieldsToUpdate(Map{"Deleted": nil}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "REMOVE #f0", expr)
+	assert.Nil(t, opt.ValueParams)
+
+	_, _, err = FieldsToUpdate(Map{}, nil)
+	assert.Error(t, err)
+	_, _, err = FieldsToUpdate(Map{"a..b": 1}, nil)
+	assert.Error(t, err)
+
+	// 重叠的路径
+	_, _, err = FieldsToUpdate(Map{"Profile": Map{"Nick": "x"}, "Profile.Nick": "y"}, nil)
+	assert.EqualError(t, err, "Overlapping field paths: Profile and Profile.Nick")
+	_, _, err = FieldsToUpdate(Map{"Logs": nil, "Logs-x": 1, "Logs[0]": 1}, nil)
+	assert.EqualError(t, err, "Overlapping field paths: Logs and Logs[0]")
+	_, _, err = FieldsToUpdate(Map{"Profile": 1, "ProfileNick": 2}, nil)
+	assert.NoError(t, err)
+}
+
+func TestModelFields(t *testing.T) {
+	nick := "x"
+	profile := &Profile{Id: "1", Nick: &nick}
+	fields, err := ModelFields(profile, "dynamodb")
+	assert.NoError(t, err)
+	assert.Equal(t, Map{"nick": &nick}, fields)
+
+	// 列出的字段即使为空也会更新，为 nil 的 map 会被删除
+	fields, err = ModelFields(profile, "dynamodb", "CreatedAt", "tags")
+	assert.NoError(t, err)
+	assert.Equal(t, Map{"created_at": int64(0), "tags": nil}, fields)
+
+	_, err = ModelFields(profile, "dynamodb", "Id")
+	assert.Error(t, err)
+	_, err = ModelFields(profile, "dynamodb", "NotExists")
+	assert.Error(t, err)
+
+	// 嵌入的结构体指针为 nil 时，列出的字段会被删除
+	pet := &Pet{Id: "1", Owner: &Owner{OwnerName: "Tom"}}
+	fields, err = ModelFields(pet, "dynamodb")
+	assert.NoError(t, err)
+	assert.Equal(t, Map{"owner_name": "Tom"}, fields)
+	fields, err = ModelFields(pet, "dynamodb", "CreatedAt")
+	assert.NoError(t, err)
+	assert.Equal(t, Map{"created_at": nil}, fields)
+}
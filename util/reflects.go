@@ -3,6 +3,7 @@ package util
 import (
 	"errors"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -26,16 +27,21 @@ func ExtractModelInfo(pkField string, skField string, model interface{}) (map[st
 	return keys, rest
 }
 
-// MapToExpression convert {"a":"123"} to "a=:a" and {":a", "123"}.
-// returns expression and attribute Map
+// MapToExpression convert {"a":"123", "b.c": 1} to "a=:a and b.c=:b_c" and {":a": "123", ":b_c": 1}.
+// keys are sorted, returns expression and attribute Map
 func MapToExpression(m map[string]interface{}) (string, map[string]interface{}) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 	var strs []string
 	attr := make(map[string]interface{})
-	for k, v := range m {
-		// a=:a
-		// TODO support a.b=:a_b
-		strs = append(strs, k+"=:"+k)
-		attr[":"+k] = v
+	for _, k := range keys {
+		// a.b=:a_b
+		placeholder := ":" + strings.Replace(k, ".", "_", -1)
+		strs = append(strs, k+"="+placeholder)
+		attr[placeholder] = m[k]
 	}
 	return strings.Join(strs, " and "), attr
 }
@@ -57,8 +57,8 @@ func TestMapToExpression(t *testing.T) {
 		want  string
 		want1 map[string]interface{}
 	}{
-		{"PlainObject", args{map[string]interface{}{"Author": "Tom", "Title": "Hello", "Age": 13}}, "Author=:Author and Title=:Title and Age=:Age", map[string]interface{}{":Author": "Tom", ":Title": "Hello", ":Age": 13}},
-		// TODO: Add test cases for a.b=:a_b
+		{"PlainObject", args{map[string]interface{}{"Author": "Tom", "Title": "Hello", "Age": 13}}, "Age=:Age and Author=:Author and Title=:Title", map[string]interface{}{":Author": "Tom", ":Title": "Hello", ":Age": 13}},
+		{"NestedPath", args{map[string]interface{}{"Info.Lang": "go", "Age": 13}}, "Age=:Age and Info.Lang=:Info_Lang", map[string]interface{}{":Info_Lang": "go", ":Age": 13}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {